@@ -23,6 +23,8 @@ Usage:
 		}
 	}()
 
+The pseudo-terminals (OpenPTY, StartCommand) are supported in Linux and macOS.
+
 Important
 
 The "go test" tool runs tests with standard input connected to standard
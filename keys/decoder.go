@@ -0,0 +1,344 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// The references about the escape sequences sent by the keyboard have been got
+// from http://invisible-island.net/xterm/ctlseqs/ctlseqs.html and
+// http://www.leonerd.org.uk/hacks/fixterms/
+
+package keys
+
+import (
+	"unicode/utf8"
+
+	"github.com/tredoe/term/sys"
+)
+
+// Introducers of escape sequences, after of the escape character.
+const (
+	_CSI = '['
+	_SS3 = 'O'
+)
+
+// Keys sent through a sequence "CSI number ~".
+var tildeKeys = map[int]Code{
+	1: Home, 2: Insert, 3: Delete, 4: End, 5: PageUp, 6: PageDown,
+	7: Home, 8: End,
+	11: F1, 12: F2, 13: F3, 14: F4, 15: F5,
+	17: F6, 18: F7, 19: F8, 20: F9, 21: F10,
+	23: F11, 24: F12,
+}
+
+// Keys sent through a sequence "CSI final" or "SS3 final".
+var finalKeys = map[byte]Code{
+	'A': Up, 'B': Down, 'C': Right, 'D': Left,
+	'H': Home, 'F': End,
+	'P': F1, 'Q': F2, 'R': F3, 'S': F4,
+}
+
+// A Decoder decodes the bytes got from a terminal into keys.
+//
+// The bytes are added through Feed, and the keys are got through Next until
+// it reports that there are not enough bytes to decode the next key.
+type Decoder struct {
+	buf []byte
+}
+
+// Feed adds the bytes p to decode.
+func (d *Decoder) Feed(p []byte) {
+	d.buf = append(d.buf, p...)
+}
+
+// Pending reports whether there are bytes that have not been decoded yet.
+func (d *Decoder) Pending() bool {
+	return len(d.buf) != 0
+}
+
+// Reset discards the bytes that have not been decoded yet.
+func (d *Decoder) Reset() {
+	d.buf = d.buf[:0]
+}
+
+// Next returns the next key decoded. It reports false if there are not enough
+// bytes to decode it, i.e. when an escape sequence has not been received in
+// full.
+func (d *Decoder) Next() (k Key, ok bool) {
+	k, n := decode(d.buf)
+	if n == 0 {
+		return Key{}, false
+	}
+	d.consume(n)
+	return k, true
+}
+
+// Flush returns the next key decoded from the bytes pending, whether or not
+// the sequence has been received in full. It is used when there are no more
+// bytes to wait for, so a lone escape character is decoded as the Escape key,
+// and an escape sequence cut off is decoded as its first character pressed
+// together with Alt. It reports false if there are not bytes pending.
+func (d *Decoder) Flush() (k Key, ok bool) {
+	if len(d.buf) == 0 {
+		return Key{}, false
+	}
+	if k, ok = d.Next(); ok {
+		return k, true
+	}
+
+	n := 1
+	switch {
+	case d.buf[0] != sys.K_ESCAPE: // incomplete UTF-8 encoding
+		k = Key{Code: Rune, Rune: utf8.RuneError}
+	case len(d.buf) == 1:
+		k = Key{Code: Escape}
+	case d.buf[1] == _CSI || d.buf[1] == _SS3:
+		k = Key{Code: Rune, Rune: rune(d.buf[1]), Mods: ModAlt}
+		n = 2
+	default:
+		k = Key{Code: Escape}
+	}
+	d.consume(n)
+	return k, true
+}
+
+// escapePending reports whether the bytes pending start an escape sequence.
+func (d *Decoder) escapePending() bool {
+	return len(d.buf) != 0 && d.buf[0] == sys.K_ESCAPE
+}
+
+// consume removes the first n bytes.
+func (d *Decoder) consume(n int) {
+	d.buf = d.buf[:copy(d.buf, d.buf[n:])]
+}
+
+// == Decoding
+//
+
+// decode decodes the first key in p, returning it and the number of bytes
+// used. If p does not contain a complete key, it returns 0 bytes used.
+func decode(p []byte) (k Key, n int) {
+	if len(p) == 0 {
+		return Key{}, 0
+	}
+	if p[0] == sys.K_ESCAPE {
+		return decodeEscape(p)
+	}
+	if p[0] < 0x20 || p[0] == sys.K_BACK {
+		return decodeControl(p[0]), 1
+	}
+	if !utf8.FullRune(p) {
+		return Key{}, 0
+	}
+	r, n := utf8.DecodeRune(p)
+	return Key{Code: Rune, Rune: r}, n
+}
+
+// decodeControl decodes a control character.
+func decodeControl(c byte) Key {
+	switch c {
+	case sys.K_RETURN:
+		return Key{Code: Enter}
+	case sys.K_TAB:
+		return Key{Code: Tab}
+	case sys.K_BACK:
+		return Key{Code: Backspace}
+	case sys.K_ESCAPE:
+		return Key{Code: Escape}
+	case 0:
+		return Key{Code: Rune, Rune: '@', Mods: ModCtrl}
+	}
+	if c <= sys.K_CTRL_Z {
+		return Key{Code: Rune, Rune: rune('a' + c - sys.K_CTRL_A), Mods: ModCtrl}
+	}
+	return Key{Code: Rune, Rune: rune('@' + c), Mods: ModCtrl} // \ ] ^ _
+}
+
+// decodeEscape decodes a key which starts with the escape character.
+func decodeEscape(p []byte) (k Key, n int) {
+	if len(p) == 1 {
+		return Key{}, 0
+	}
+
+	switch p[1] {
+	case _CSI:
+		return decodeCSI(p)
+	case _SS3:
+		return decodeSS3(p)
+	}
+
+	// Alt+key: the escape character followed by a key.
+	if k, n = decode(p[1:]); n == 0 {
+		return Key{}, 0
+	}
+	k.Mods |= ModAlt
+	return k, n + 1
+}
+
+// decodeCSI decodes a sequence "ESC [ parameters intermediates final".
+func decodeCSI(p []byte) (k Key, n int) {
+	i := 2
+
+	// Linux console: function keys F1-F5 are sent as "ESC [ [ A-E".
+	if len(p) > i && p[i] == '[' {
+		if len(p) == i+1 {
+			return Key{}, 0
+		}
+		if c := p[i+1]; c >= 'A' && c <= 'E' {
+			return Key{Code: F1 + Code(c-'A')}, i + 2
+		}
+		return Key{Code: Unknown}, i + 2
+	}
+
+	start := i
+	for ; i < len(p) && p[i] >= 0x30 && p[i] <= 0x3F; i++ { // parameters
+	}
+	params := p[start:i]
+	for ; i < len(p) && p[i] >= 0x20 && p[i] <= 0x2F; i++ { // intermediates
+	}
+	if i == len(p) {
+		return Key{}, 0
+	}
+
+	final := p[i]
+	n = i + 1
+	if final < 0x40 || final > 0x7E { // malformed sequence
+		return Key{Code: Unknown}, i
+	}
+	if len(params) != 0 && params[0] >= '<' { // private sequence, i.e. mouse
+		return Key{Code: Unknown}, n
+	}
+	args := parseParams(params)
+
+	switch final {
+	case '~', '^', '$', '@':
+		if len(args) == 0 {
+			break
+		}
+		if args[0] == 27 && len(args) >= 3 { // xterm: "CSI 27 ; modifiers ; code ~"
+			return withMods(keyFromCodepoint(args[2]), args[1]), n
+		}
+		code, ok := tildeKeys[args[0]]
+		if !ok {
+			break
+		}
+		k = Key{Code: code}
+		if len(args) >= 2 {
+			k = withMods(k, args[1])
+		}
+		switch final { // rxvt
+		case '^':
+			k.Mods |= ModCtrl
+		case '$':
+			k.Mods |= ModShift
+		case '@':
+			k.Mods |= ModCtrl | ModShift
+		}
+		return k, n
+
+	case 'u': // fixterms: "CSI codepoint ; modifiers u"
+		if len(args) == 0 {
+			break
+		}
+		k = keyFromCodepoint(args[0])
+		if len(args) >= 2 {
+			k = withMods(k, args[1])
+		}
+		return k, n
+
+	case 'Z':
+		return Key{Code: Tab, Mods: ModShift}, n
+
+	case 'a', 'b', 'c', 'd': // rxvt: Shift+arrow
+		return Key{Code: finalKeys[final-'a'+'A'], Mods: ModShift}, n
+
+	default:
+		code, ok := finalKeys[final]
+		if !ok {
+			break
+		}
+		k = Key{Code: code}
+		if len(args) >= 2 {
+			k = withMods(k, args[1])
+		}
+		return k, n
+	}
+	return Key{Code: Unknown}, n
+}
+
+// decodeSS3 decodes a sequence "ESC O [modifiers] final".
+func decodeSS3(p []byte) (k Key, n int) {
+	i := 2
+	mods := 0
+	for ; i < len(p) && p[i] >= '0' && p[i] <= '9'; i++ {
+		mods = mods*10 + int(p[i]-'0')
+	}
+	if i == len(p) {
+		return Key{}, 0
+	}
+
+	final := p[i]
+	n = i + 1
+
+	switch {
+	case final == 'M': // keypad Enter
+		k = Key{Code: Enter}
+	case final >= 'a' && final <= 'd': // rxvt: Ctrl+arrow
+		k = Key{Code: finalKeys[final-'a'+'A'], Mods: ModCtrl}
+	default:
+		code, ok := finalKeys[final]
+		if !ok {
+			return Key{Code: Unknown}, n
+		}
+		k = Key{Code: code}
+	}
+	if mods != 0 {
+		k = withMods(k, mods)
+	}
+	return k, n
+}
+
+// == Utility
+//
+
+// parseParams parses the parameters of a CSI sequence, separated by ';'.
+// The sub-parameters, separated by ':', are skipped.
+func parseParams(p []byte) []int {
+	if len(p) == 0 {
+		return nil
+	}
+
+	args := []int{0}
+	sub := false
+	for _, c := range p {
+		switch {
+		case c == ';':
+			args = append(args, 0)
+			sub = false
+		case c == ':':
+			sub = true
+		case c >= '0' && c <= '9' && !sub:
+			last := len(args) - 1
+			args[last] = args[last]*10 + int(c-'0')
+		}
+	}
+	return args
+}
+
+// withMods adds to the key the modifiers given in a parameter of an escape
+// sequence, which is 1 plus the bit mask of modifiers.
+func withMods(k Key, param int) Key {
+	if param > 1 {
+		k.Mods |= Mod(param-1) & (ModShift | ModAlt | ModCtrl | ModMeta)
+	}
+	return k
+}
+
+// keyFromCodepoint returns the key for a Unicode code point sent in an escape
+// sequence.
+func keyFromCodepoint(c int) Key {
+	if c < 0x20 || c == sys.K_BACK {
+		return decodeControl(byte(c))
+	}
+	return Key{Code: Rune, Rune: rune(c)}
+}
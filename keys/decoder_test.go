@@ -0,0 +1,229 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package keys
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		in   string
+		keys []Key
+	}{
+		{"a€", []Key{{Rune, 'a', 0}, {Rune, '€', 0}}},
+		{"\r\t\x7f", []Key{{Code: Enter}, {Code: Tab}, {Code: Backspace}}},
+		{"\x01\x08\x1a\x1f\x00", []Key{
+			{Rune, 'a', ModCtrl}, {Rune, 'h', ModCtrl}, {Rune, 'z', ModCtrl},
+			{Rune, '_', ModCtrl}, {Rune, '@', ModCtrl},
+		}},
+
+		// CSI
+		{"\x1b[A\x1b[B\x1b[C\x1b[D", []Key{{Code: Up}, {Code: Down}, {Code: Right}, {Code: Left}}},
+		{"\x1b[H\x1b[F\x1b[1~\x1b[4~", []Key{{Code: Home}, {Code: End}, {Code: Home}, {Code: End}}},
+		{"\x1b[2~\x1b[3~\x1b[5~\x1b[6~", []Key{{Code: Insert}, {Code: Delete}, {Code: PageUp}, {Code: PageDown}}},
+		{"\x1b[1;5D\x1b[1;5C", []Key{{Code: Left, Mods: ModCtrl}, {Code: Right, Mods: ModCtrl}}},
+		{"\x1b[1;3A\x1b[1;2B\x1b[1;8H", []Key{
+			{Code: Up, Mods: ModAlt}, {Code: Down, Mods: ModShift},
+			{Code: Home, Mods: ModShift | ModAlt | ModCtrl},
+		}},
+		{"\x1b[3;5~\x1b[3^", []Key{{Code: Delete, Mods: ModCtrl}, {Code: Delete, Mods: ModCtrl}}},
+		{"\x1b[15~\x1b[24~\x1b[1;2P\x1b[[A", []Key{
+			{Code: F5}, {Code: F12}, {Code: F1, Mods: ModShift}, {Code: F1},
+		}},
+		{"\x1b[Z", []Key{{Code: Tab, Mods: ModShift}}},
+		{"\x1b[97;5u\x1b[13;3u", []Key{{Rune, 'a', ModCtrl}, {Code: Enter, Mods: ModAlt}}},
+		{"\x1b[27;5;106~", []Key{{Rune, 'j', ModCtrl}}},
+		{"\x1b[99~\x1b[<0;1;1Mx", []Key{{Code: Unknown}, {Code: Unknown}, {Rune, 'x', 0}}},
+
+		// SS3
+		{"\x1bOH\x1bOF\x1bOP\x1bOS", []Key{{Code: Home}, {Code: End}, {Code: F1}, {Code: F4}}},
+		{"\x1bO5D\x1bOM", []Key{{Code: Left, Mods: ModCtrl}, {Code: Enter}}},
+
+		// Alt
+		{"\x1bf\x1bB\x1b\x7f", []Key{{Rune, 'f', ModAlt}, {Rune, 'B', ModAlt}, {Code: Backspace, Mods: ModAlt}}},
+		{"\x1b\x19\x1b\x1b[A", []Key{{Rune, 'y', ModAlt | ModCtrl}, {Code: Up, Mods: ModAlt}}},
+	}
+
+	for _, tt := range tests {
+		var d Decoder
+		d.Feed([]byte(tt.in))
+
+		for i, want := range tt.keys {
+			k, ok := d.Next()
+			if !ok {
+				t.Errorf("%q: key #%d: expected to be decoded", tt.in, i)
+				break
+			}
+			if k != want {
+				t.Errorf("%q: key #%d: expected %v, got %v", tt.in, i, want, k)
+			}
+		}
+		if d.Pending() {
+			t.Errorf("%q: expected no bytes pending", tt.in)
+		}
+	}
+}
+
+func TestDecoderIncomplete(t *testing.T) {
+	var d Decoder
+
+	for _, in := range []string{"\x1b", "\x1b[", "\x1b[1;5", "\x1bO", "\xe2\x82"} {
+		d.Reset()
+		d.Feed([]byte(in))
+		if _, ok := d.Next(); ok {
+			t.Errorf("%q: expected to need more bytes", in)
+		}
+	}
+
+	// The rest of the sequence is got later.
+	d.Reset()
+	d.Feed([]byte("\x1b[1;"))
+	d.Feed([]byte("5D"))
+	if k, _ := d.Next(); k != (Key{Code: Left, Mods: ModCtrl}) {
+		t.Errorf("expected Ctrl+Left, got %v", k)
+	}
+
+	flushes := []struct {
+		in  string
+		key Key
+	}{
+		{"\x1b", Key{Code: Escape}},
+		{"\x1b[", Key{Rune, '[', ModAlt}},
+		{"\xe2\x82", Key{Rune, '�', 0}},
+	}
+	for _, tt := range flushes {
+		d.Reset()
+		d.Feed([]byte(tt.in))
+		if k, _ := d.Flush(); k != tt.key {
+			t.Errorf("%q: expected %v at flushing, got %v", tt.in, tt.key, k)
+		}
+	}
+}
+
+func TestReaderEscape(t *testing.T) {
+	pr, pw := io.Pipe()
+	r := NewReader(pr)
+	defer r.Close()
+	r.Timeout = 20 * time.Millisecond
+
+	go func() {
+		pw.Write([]byte("\x1b"))
+		time.Sleep(100 * time.Millisecond)
+		pw.Write([]byte("\x1b[A"))
+		pw.Write([]byte("\x1b"))
+		pw.Close()
+	}()
+
+	k, err := r.ReadKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.UnreadKey(k)
+
+	for _, want := range []Key{{Code: Escape}, {Code: Up}, {Code: Escape}} {
+		k, err := r.ReadKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k != want {
+			t.Errorf("expected %v, got %v", want, k)
+		}
+	}
+	if _, err := r.ReadKey(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestReaderContext(t *testing.T) {
+	pr, pw := io.Pipe()
+	r := NewReader(pr)
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.ReadKeyContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// The read in progress is got by the next call.
+	go pw.Write([]byte("a"))
+	k, err := r.ReadKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Key{Rune, 'a', 0}); k != want {
+		t.Errorf("expected %v, got %v", want, k)
+	}
+}
+
+func TestReaderInterrupt(t *testing.T) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+	r := NewReader(pr)
+	if r.poll == nil {
+		t.Skip("the reads can not be interrupted")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = r.ReadKeyContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	go pw.Write([]byte("a"))
+	if k, err := r.ReadKey(); err != nil || k != (Key{Rune, 'a', 0}) {
+		t.Errorf("expected key a, got %v, %v", k, err)
+	}
+
+	// The input is not read after of closing it.
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	r.ReadKeyContext(ctx)
+	r.Close()
+
+	pw.Write([]byte("b"))
+	buf := make([]byte, 8)
+	if n, err := pr.Read(buf); err != nil || string(buf[:n]) != "b" {
+		t.Errorf("expected to read %q, got %q, %v", "b", buf[:n], err)
+	}
+}
+
+func TestReaderClose(t *testing.T) {
+	pr, _ := io.Pipe()
+	r := NewReader(pr)
+	r.Close()
+	r.Close()
+
+	if _, err := r.ReadKey(); err != io.ErrClosedPipe {
+		t.Errorf("expected closed pipe, got %v", err)
+	}
+}
+
+func TestKeyString(t *testing.T) {
+	tests := []struct {
+		key Key
+		s   string
+	}{
+		{Key{Rune, 'a', ModCtrl}, "Ctrl+a"},
+		{Key{Code: Left, Mods: ModCtrl | ModShift}, "Ctrl+Shift+Left"},
+		{Key{Rune, ' ', ModAlt}, "Alt+Space"},
+		{Key{Code: F11}, "F11"},
+	}
+	for _, tt := range tests {
+		if s := tt.key.String(); s != tt.s {
+			t.Errorf("expected %q, got %q", tt.s, s)
+		}
+	}
+}
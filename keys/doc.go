@@ -0,0 +1,43 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+/*
+Package keys decodes the bytes got from a terminal into key events.
+
+A key is given by a code for the special keys (arrows, function keys, ...) or
+by a character, besides of the modifiers pressed (Shift, Alt, Ctrl, Meta).
+
+The control characters are decoded as the letter pressed together with Ctrl,
+so Ctrl+a is the key {Rune, 'a', ModCtrl}, and a character preceded by the
+escape character is decoded as the character pressed together with Alt.
+
+The escape sequences are parsed in full:
+
+	CSI: ESC [ parameters final, i.e. "\x1b[1;5D" (Ctrl+Left), "\x1b[3~" (Delete)
+	SS3: ESC O final, i.e. "\x1bOH" (Home), "\x1bOP" (F1)
+
+The modifiers are decoded from the second parameter of a CSI sequence, which
+is 1 plus a bit mask (Shift=1, Alt=2, Ctrl=4, Meta=8).
+
+Since the Escape key sends the same byte that starts an escape sequence, it is
+considered pressed alone when no other byte is received after EscapeTimeout.
+
+Usage:
+
+	r := keys.NewReader(os.Stdin) // The terminal should be in raw mode.
+	defer r.Close()
+
+	for {
+		k, err := r.ReadKey()
+		if err != nil {
+			// Handle error
+		}
+		if k.Code == keys.Enter {
+			break
+		}
+	}
+*/
+package keys
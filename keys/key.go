@@ -0,0 +1,132 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package keys
+
+import (
+	"strconv"
+	"unicode"
+)
+
+// Code represents the code of a key.
+type Code int
+
+const (
+	Rune    Code = iota // A character, given in Key.Rune
+	Unknown             // A sequence not recognized
+	Enter
+	Tab
+	Backspace
+	Escape
+
+	Up
+	Down
+	Right
+	Left
+	Home
+	End
+	Insert
+	Delete
+	PageUp
+	PageDown
+
+	F1
+	F2
+	F3
+	F4
+	F5
+	F6
+	F7
+	F8
+	F9
+	F10
+	F11
+	F12
+)
+
+var codeNames = [...]string{
+	Rune:      "Rune",
+	Unknown:   "Unknown",
+	Enter:     "Enter",
+	Tab:       "Tab",
+	Backspace: "Backspace",
+	Escape:    "Escape",
+
+	Up:       "Up",
+	Down:     "Down",
+	Right:    "Right",
+	Left:     "Left",
+	Home:     "Home",
+	End:      "End",
+	Insert:   "Insert",
+	Delete:   "Delete",
+	PageUp:   "PageUp",
+	PageDown: "PageDown",
+}
+
+func (c Code) String() string {
+	if c >= F1 && c <= F12 {
+		return "F" + strconv.Itoa(int(c-F1)+1)
+	}
+	if c >= 0 && int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "Code(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Mod represents the modifier keys pressed together with a key.
+type Mod uint8
+
+const (
+	ModShift Mod = 1 << iota
+	ModAlt
+	ModCtrl
+	ModMeta
+)
+
+func (m Mod) String() string {
+	s := ""
+	if m&ModCtrl != 0 {
+		s += "Ctrl+"
+	}
+	if m&ModAlt != 0 {
+		s += "Alt+"
+	}
+	if m&ModShift != 0 {
+		s += "Shift+"
+	}
+	if m&ModMeta != 0 {
+		s += "Meta+"
+	}
+	return s
+}
+
+// A Key represents a key pressed.
+type Key struct {
+	Code Code
+	Rune rune // The character when Code is Rune
+	Mods Mod
+}
+
+// IsRune reports whether the key is a character without modifiers, that is to
+// say, a character to be inserted, like the joiners used in emoji sequences.
+func (k Key) IsRune() bool {
+	return k.Code == Rune && k.Mods&^ModShift == 0 && !unicode.IsControl(k.Rune)
+}
+
+// String returns the key like "Ctrl+Left" or "Alt+f".
+func (k Key) String() string {
+	if k.Code != Rune {
+		return k.Mods.String() + k.Code.String()
+	}
+	if k.Rune == ' ' {
+		return k.Mods.String() + "Space"
+	}
+	if unicode.IsPrint(k.Rune) {
+		return k.Mods.String() + string(k.Rune)
+	}
+	return k.Mods.String() + strconv.QuoteRune(k.Rune)
+}
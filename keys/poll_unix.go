@@ -0,0 +1,83 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// +build !plan9,!windows
+
+package keys
+
+import (
+	"io"
+
+	"golang.org/x/sys/unix"
+)
+
+// A poller waits until a file can be read, or until it is woken up, so a read
+// can be interrupted before of starting.
+type poller struct {
+	fd   int
+	wake [2]int // Pipe written to wake up the wait
+}
+
+// newPoller returns a poller of r whether it is a file, or nil.
+func newPoller(r io.Reader) *poller {
+	f, ok := r.(interface{ Fd() uintptr })
+	if !ok {
+		return nil
+	}
+
+	p := &poller{fd: int(f.Fd())}
+	if err := unix.Pipe(p.wake[:]); err != nil {
+		return nil
+	}
+	for _, fd := range p.wake {
+		unix.CloseOnExec(fd)
+		unix.SetNonblock(fd, true)
+	}
+	return p
+}
+
+// wait waits until the file can be read. It reports false whether it has been
+// woken up before.
+func (p *poller) wait() (bool, error) {
+	nfd := p.fd
+	if p.wake[0] > nfd {
+		nfd = p.wake[0]
+	}
+
+	for {
+		var fds unix.FdSet
+		fds.Set(p.fd)
+		fds.Set(p.wake[0])
+
+		if _, err := unix.Select(nfd+1, &fds, nil, nil, nil); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return false, err
+		}
+		if fds.IsSet(p.wake[0]) {
+			buf := make([]byte, 16)
+			for {
+				if n, _ := unix.Read(p.wake[0], buf); n <= 0 {
+					break
+				}
+			}
+			return false, nil
+		}
+		return true, nil
+	}
+}
+
+// wakeUp wakes up the wait in progress, or else the next one.
+func (p *poller) wakeUp() {
+	unix.Write(p.wake[1], []byte{0})
+}
+
+// close closes the pipe used to wake up.
+func (p *poller) close() {
+	unix.Close(p.wake[0])
+	unix.Close(p.wake[1])
+}
@@ -0,0 +1,181 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package keys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// EscapeTimeout is the time to wait for the rest of an escape sequence, by
+// default, before of considering that the Escape key has been pressed alone.
+var EscapeTimeout = 50 * time.Millisecond
+
+// A Reader reads keys from an input.
+//
+// The input is read from a goroutine, which only reads when a key is
+// requested, so no byte is got from the input out of a call to ReadKey.
+// A read which is not waited for, like when the context of ReadKeyContext is
+// done, is interrupted whether the input is a file, like a terminal, which has
+// not got bytes yet; else, it is kept for the next call.
+type Reader struct {
+	// Timeout is the time to wait for the rest of an escape sequence.
+	Timeout time.Duration
+
+	dec  Decoder
+	err  error // Error got from the input, returned once the bytes are decoded
+	back []Key // Keys unread
+
+	req     chan bool
+	res     chan result
+	reading bool // A read has been requested and not received yet
+	closed  bool
+	poll    *poller // Used to interrupt the reads; nil if it is not a file
+}
+
+// errInterrupted is got from a read which has been interrupted.
+var errInterrupted = errors.New("read interrupted")
+
+// result is the result of a read.
+type result struct {
+	p   []byte
+	err error
+}
+
+// NewReader returns a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	kr := &Reader{
+		Timeout: EscapeTimeout,
+		req:     make(chan bool),
+		res:     make(chan result, 1),
+		poll:    newPoller(r),
+	}
+
+	go func() {
+		buf := make([]byte, 256)
+		if kr.poll != nil {
+			defer kr.poll.close()
+		}
+
+		for range kr.req {
+			if kr.poll != nil {
+				if ok, err := kr.poll.wait(); !ok {
+					if err == nil {
+						err = errInterrupted
+					}
+					kr.res <- result{err: err}
+					continue
+				}
+			}
+			n, err := r.Read(buf)
+			p := make([]byte, n)
+			copy(p, buf)
+			kr.res <- result{p, err}
+		}
+	}()
+	return kr
+}
+
+// Close stops the goroutine that reads from the input. If a read is in
+// progress and it can not be interrupted, the goroutine finishes after that
+// read returns.
+// The keys can not be read after of closing it.
+func (r *Reader) Close() {
+	if !r.closed {
+		r.interrupt()
+		r.closed = true
+		close(r.req)
+	}
+}
+
+// interrupt interrupts the read in progress whether the input is a file which
+// has not got bytes yet; else, the bytes got are kept.
+func (r *Reader) interrupt() {
+	if !r.reading || r.poll == nil {
+		return
+	}
+	r.poll.wakeUp()
+	r.receive(<-r.res)
+}
+
+// receive gets the result of a read.
+func (r *Reader) receive(res result) {
+	r.reading = false
+	r.dec.Feed(res.p)
+	if res.err != errInterrupted {
+		r.err = res.err
+	}
+}
+
+// UnreadKey unreads the key k, so it is the next one returned by ReadKey.
+func (r *Reader) UnreadKey(k Key) {
+	r.back = append(r.back, k)
+}
+
+// ReadKey reads the next key.
+func (r *Reader) ReadKey() (Key, error) {
+	return r.ReadKeyContext(context.Background())
+}
+
+// ReadKeyContext reads the next key, until the context is done; then, it
+// returns the error of the context.
+// It returns io.ErrClosedPipe whether the reader has been closed.
+func (r *Reader) ReadKeyContext(ctx context.Context) (Key, error) {
+	if r.closed {
+		return Key{}, io.ErrClosedPipe
+	}
+
+	if n := len(r.back); n != 0 {
+		k := r.back[n-1]
+		r.back = r.back[:n-1]
+		return k, nil
+	}
+
+	for {
+		if k, ok := r.dec.Next(); ok {
+			return k, nil
+		}
+		if r.err != nil {
+			if k, ok := r.dec.Flush(); ok {
+				return k, nil
+			}
+			return Key{}, r.err
+		}
+
+		if !r.reading {
+			r.req <- true
+			r.reading = true
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if r.dec.escapePending() {
+			timer = time.NewTimer(r.Timeout)
+			timeout = timer.C
+		}
+
+		select {
+		case res := <-r.res:
+			r.receive(res)
+		case <-timeout:
+			if k, ok := r.dec.Flush(); ok {
+				return k, nil
+			}
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			r.interrupt()
+			return Key{}, ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// +build linux
+
+package term
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"testing"
+
+	"github.com/tredoe/term/sys"
+)
+
+func TestOpenPTY(t *testing.T) {
+	master, slave, err := OpenPTY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+	defer slave.Close()
+
+	if !IsTerminal(int(slave.Fd())) {
+		t.Error("expected the slave to be a terminal")
+	}
+
+	if err = SetSize(int(master.Fd()), 24, 80); err != nil {
+		t.Fatal(err)
+	}
+	var ws sys.Winsize
+	if err = sys.GetWinsize(int(slave.Fd()), &ws); err != nil {
+		t.Fatal(err)
+	}
+	if ws.Row != 24 || ws.Col != 80 {
+		t.Errorf("expected size 24x80, got %dx%d", ws.Row, ws.Col)
+	}
+
+	// The slave receives what is written in the master.
+	if _, err = master.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := slave.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "ping\n" {
+		t.Errorf("expected to read %q, got %q", "ping\n", buf[:n])
+	}
+}
+
+func TestStartCommand(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+
+	// "/dev/tty" can only be opened by a process with a controlling terminal.
+	cmd := exec.Command(sh, "-c", "stty size </dev/tty")
+	master, err := StartCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+
+	var out bytes.Buffer
+	io.Copy(&out, master) // it returns EIO when the slave is closed.
+
+	if err = cmd.Wait(); err != nil {
+		t.Fatalf("%s: %s", err, out.Bytes())
+	}
+	if got := string(bytes.TrimSpace(out.Bytes())); got != "0 0" {
+		t.Errorf("expected size %q, got %q", "0 0", got)
+	}
+}
+
+func TestNewFromFile(t *testing.T) {
+	master, slave, err := OpenPTY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+	defer slave.Close()
+
+	if err = SetSize(int(master.Fd()), 30, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	ter, err := NewFromFile(slave, slave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ter.Fd() != int(slave.Fd()) {
+		t.Error("expected to use the file descriptor of the input")
+	}
+	if ter.Input() != slave || ter.Output() != slave {
+		t.Error("expected to use the given input and output")
+	}
+
+	row, col, err := ter.GetSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row != 30 || col != 100 {
+		t.Errorf("expected size 30x100, got %dx%d", row, col)
+	}
+
+	if err = ter.RawMode(); err != nil {
+		t.Fatal(err)
+	}
+	if err = ter.Restore(); err != nil {
+		t.Fatal(err)
+	}
+}
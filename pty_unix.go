@@ -0,0 +1,115 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// +build linux darwin
+
+package term
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/tredoe/term/sys"
+	"golang.org/x/sys/unix"
+)
+
+// OpenPTY opens a new pseudo-terminal, returning both master and slave. It is
+// supported in Linux and macOS.
+//
+// What is written to the master is received by the slave as input, and what is
+// written to the slave is read from the master, so a program using the slave as
+// its terminal can be driven from the master.
+func OpenPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile(sys.PTMX, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			master.Close()
+		}
+	}()
+
+	if err = sys.Unlockpt(int(master.Fd())); err != nil {
+		return nil, nil, os.NewSyscallError("sys.Unlockpt", err)
+	}
+	name, err := sys.Ptsname(int(master.Fd()))
+	if err != nil {
+		return nil, nil, os.NewSyscallError("sys.Ptsname", err)
+	}
+
+	slave, err = os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+// StartCommand starts the command cmd attached to the slave of a new
+// pseudo-terminal, returning its master.
+//
+// The standard input, output and error of the command which are not already
+// set are connected to the slave, which becomes the controlling terminal of the
+// command in a new session.
+func StartCommand(cmd *exec.Cmd) (master *os.File, err error) {
+	master, slave, err := OpenPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close() // the child process has its own copy.
+
+	if err = StartCommandIn(cmd, slave); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
+
+// StartCommandIn starts the command cmd attached to the slave of a
+// pseudo-terminal, like StartCommand, but using a slave already opened.
+func StartCommandIn(cmd *exec.Cmd, slave *os.File) error {
+	if cmd.Stdin == nil {
+		cmd.Stdin = slave
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = slave
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = slave
+	}
+
+	// The controlling terminal is given by the file descriptor in the child.
+	ctty := -1
+	switch slave {
+	case cmd.Stdin:
+		ctty = 0
+	case cmd.Stdout:
+		ctty = 1
+	case cmd.Stderr:
+		ctty = 2
+	default:
+		for i, f := range cmd.ExtraFiles {
+			if f == slave {
+				ctty = 3 + i
+				break
+			}
+		}
+		if ctty == -1 {
+			cmd.ExtraFiles = append(cmd.ExtraFiles, slave)
+			ctty = 2 + len(cmd.ExtraFiles)
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = ctty
+
+	return cmd.Start()
+}
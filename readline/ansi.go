@@ -44,6 +44,7 @@ var (
 	DelScreenToUpper = []byte("\033[2J\033[0;0H") // Erase the screen; move upper
 
 	DelToRight       = []byte("\033[0K")       // Erase to right
+	DelToDown        = []byte("\033[0J")       // Erase to right and lines below
 	DelLine_CR       = []byte("\033[2K\r")     // Erase line; carriage return
 	DelLine_cursorUp = []byte("\033[2K\033[A") // Erase line; cursor up
 
@@ -52,12 +53,14 @@ var (
 	DelBackspace = []byte("\033[D\033[P")
 
 	// Misc.
+	VisibleBell = []byte("\033[?5h\033[?5l") // Reverse video on and off
 	//InsertChar  = []byte("\033[@")   // Insert CHaracter
 	//SetLineWrap = []byte("\033[?7h") // Enable Line Wrap
 )
 
 // Characters
 var (
+	Bell  = []byte{7}      // Bell -- \a
 	CR    = []byte{13}     // Carriage return -- \r
 	CRLF  = []byte{13, 10} // CR+LF is used for a new line in raw mode -- \r\n
 	CtrlC = []rune("^C")
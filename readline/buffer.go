@@ -8,15 +8,15 @@ package readline
 
 import (
 	"fmt"
+	"io"
+	"unicode"
 	"unicode/utf8"
-
-	"github.com/tredoe/term"
 )
 
 // Buffer size
 var (
-	BufferCap = 4096
-	BufferLen = 64 // Initial length
+	BufferCap = 4096 // Initial capacity; it is grown when it is exceeded
+	BufferLen = 64   // Initial length
 )
 
 // == Init
@@ -31,23 +31,51 @@ func init() {
 
 // A buffer represents the line buffer.
 type buffer struct {
-	columns   int // Number of columns for actual window
-	promptLen int
-	pos       int    // Pointer position into buffer
-	size      int    // Amount of characters added
-	data      []rune // Text buffer
+	columns     int // Number of columns for actual window
+	promptLen   int
+	promptWidth int    // Number of columns used by the prompt
+	pos         int    // Pointer position into buffer
+	size        int    // Amount of characters added
+	data        []rune // Text buffer
+
+	contPrompt []rune // Prompt shown at the start of each new line of the text
+	contWidth  int    // Number of columns used by the continuation prompt
+
+	highlighter  Highlighter // Styles of the text
+	bracketStyle string      // Style of the brackets matched
+	suggester    Suggester
+	ghost        []rune // Suggestion shown after of the text
+
+	out io.Writer // Where the line is written
 }
 
-func newBuffer(promptLen, columns int) *buffer {
+func newBuffer(out io.Writer, columns int) *buffer {
 	b := new(buffer)
 
+	b.out = out
 	b.columns = columns
-	b.promptLen = promptLen
 	b.data = make([]rune, BufferLen, BufferCap)
 
 	return b
 }
 
+// reset empties the line, setting the prompt, without writing it.
+func (b *buffer) reset(prompt []rune) {
+	b.grow(len(prompt))
+	copy(b.data, prompt)
+
+	b.promptLen = len(prompt)
+	b.promptWidth = promptWidth(prompt)
+	b.pos, b.size = b.promptLen, b.promptLen
+	b.ghost = nil
+}
+
+// setContPrompt sets the prompt shown after of each new line of the text.
+func (b *buffer) setContPrompt(prompt []rune) {
+	b.contPrompt = prompt
+	b.contWidth = promptWidth(prompt)
+}
+
 // == Output
 
 // insertRune inserts a character in the cursor position.
@@ -56,12 +84,13 @@ func (b *buffer) insertRune(r rune) error {
 
 	b.grow(b.size + 1) // Check if there is free space for one more character
 
-	// Avoid a full update of the line.
-	if b.pos == b.size {
+	// Avoid a full update of the line, unless the character is combined with
+	// the previous one or the text is styled.
+	if b.pos == b.size && runeWidth(r) != 0 && !b.styled() {
 		char := make([]byte, utf8.UTFMax)
-		utf8.EncodeRune(char, r)
+		n := utf8.EncodeRune(char, r)
 
-		if _, err := term.Output.Write(char); err != nil {
+		if _, err := b.out.Write(char[:n]); err != nil {
 			return outputError(err.Error())
 		}
 	} else {
@@ -76,6 +105,21 @@ func (b *buffer) insertRune(r rune) error {
 	if useRefresh {
 		return b.refresh()
 	}
+	return b.wrap()
+}
+
+// wrap moves the cursor to the next line when the text written until the end
+// fills the last line, since the terminal keeps it at the last column.
+func (b *buffer) wrap() error {
+	end := b.size + len(b.ghost)
+	if end > b.promptLen && b.data[end-1] == '\n' {
+		return nil
+	}
+	if line, column := b.pos2xy(end); line != 0 && column == 0 {
+		if _, err := b.out.Write(CRLF); err != nil {
+			return outputError(err.Error())
+		}
+	}
 	return nil
 }
 
@@ -89,22 +133,77 @@ func (b *buffer) insertRunes(runes []rune) error {
 	return nil
 }
 
-// toBytes returns a slice of the contents of the buffer.
+// toBytes returns a slice of the contents of the buffer, without the markers
+// of characters which are not shown in the prompts. The new lines of the text
+// are written like CR+LF followed by the continuation prompt. The styles of
+// the text are written like sequences SGR, which do not use columns, and the
+// suggestion is written after of the text.
 func (b *buffer) toBytes() []byte {
-	chars := make([]byte, b.size*utf8.UTFMax)
-	var end, runeLen int
-
-	// == Each character (as integer) is encoded to []byte
-	for i := 0; i < b.size; i++ {
-		if i != 0 {
-			runeLen = utf8.EncodeRune(chars[end:], b.data[i])
-			end += runeLen
-		} else {
-			runeLen = utf8.EncodeRune(chars, b.data[i])
-			end = runeLen
+	chars := make([]byte, 0, b.size*utf8.UTFMax)
+
+	var styles []string
+	if b.styled() {
+		styles = b.styles()
+	}
+	style := "" // Style written
+
+	chars = appendPrompt(chars, b.data[:b.promptLen])
+	for i, r := range b.data[b.promptLen : b.size+len(b.ghost)] {
+		next := ""
+		if styles != nil && r != '\n' {
+			next = styles[i]
+		}
+		if next != style {
+			if style != "" {
+				chars = append(chars, ANSI_SET_OFF...)
+			}
+			if next != "" {
+				chars = append(chars, "\033["+next+"m"...)
+			}
+			style = next
 		}
+
+		if r == '\n' {
+			chars = append(chars, DelToRight...)
+			chars = append(chars, CRLF...)
+			chars = appendPrompt(chars, b.contPrompt)
+			continue
+		}
+		chars = appendRune(chars, r)
+	}
+	if style != "" {
+		chars = append(chars, ANSI_SET_OFF...)
 	}
-	return chars[:end]
+	return chars
+}
+
+// appendPrompt appends the prompt encoded to b, without the markers of
+// characters which are not shown.
+func appendPrompt(b []byte, prompt []rune) []byte {
+	for _, r := range prompt {
+		if r != ignoreStart && r != ignoreEnd {
+			b = appendRune(b, r)
+		}
+	}
+	return b
+}
+
+// appendRune appends the UTF-8 encoding of r to b.
+func appendRune(b []byte, r rune) []byte {
+	char := make([]byte, utf8.UTFMax)
+	n := utf8.EncodeRune(char, r)
+	return append(b, char[:n]...)
+}
+
+// text returns a copy of the text between the positions start and end,
+// relative to the prompt.
+func (b *buffer) text(start, end int) []rune {
+	return append([]rune(nil), b.data[b.promptLen+start:b.promptLen+end]...)
+}
+
+// prompt returns a copy of the prompt.
+func (b *buffer) prompt() []rune {
+	return append([]rune(nil), b.data[:b.promptLen]...)
 }
 
 // toString returns the contents of the buffer as a string.
@@ -112,61 +211,64 @@ func (b *buffer) toString() string { return string(b.data[b.promptLen:b.size]) }
 
 // refresh refreshes the line.
 func (b *buffer) refresh() (err error) {
-	lastLine, _ := b.pos2xy(b.size)
+	return b.refreshFrom(b.pos)
+}
+
+// refreshFrom refreshes the line when the cursor is at the position oldPos,
+// which could be different to the actual one after of an edition.
+func (b *buffer) refreshFrom(oldPos int) (err error) {
+	oldLine, _ := b.pos2xy(oldPos)
+	return b.redraw(oldLine)
+}
+
+// redraw writes the line when the cursor is at the line oldLine, relative to
+// the first one.
+func (b *buffer) redraw(oldLine int) (err error) {
+	b.suggest()
+	lastLine, _ := b.pos2xy(b.size + len(b.ghost))
 	posLine, posColumn := b.pos2xy(b.pos)
 
 	// To the first line.
-	for ln := posLine; ln > 0; ln-- {
-		if _, err = term.Output.Write(ToPreviousLine); err != nil {
+	for ln := oldLine; ln > 0; ln-- {
+		if _, err = b.out.Write(ToPreviousLine); err != nil {
 			return outputError(err.Error())
 		}
 	}
 
 	// == Write the line
-	if _, err = term.Output.Write(CR); err != nil {
+	if _, err = b.out.Write(CR); err != nil {
 		return outputError(err.Error())
 	}
-	if _, err = term.Output.Write(b.toBytes()); err != nil {
+	if _, err = b.out.Write(b.toBytes()); err != nil {
 		return outputError(err.Error())
 	}
-	if _, err = term.Output.Write(DelToRight); err != nil {
+	if err = b.wrap(); err != nil {
+		return err
+	}
+	if _, err = b.out.Write(DelToDown); err != nil {
 		return outputError(err.Error())
 	}
 
 	// == Move cursor to original position.
 	for ln := lastLine; ln > posLine; ln-- {
-		if _, err = term.Output.Write(ToPreviousLine); err != nil {
+		if _, err = b.out.Write(ToPreviousLine); err != nil {
 			return outputError(err.Error())
 		}
 	}
-	if _, err = fmt.Fprintf(term.Output, "\r\033[%dC", posColumn); err != nil {
+	if _, err = b.out.Write(CR); err != nil {
 		return outputError(err.Error())
 	}
-
-	return nil
-}
-
-// == Movement
-
-// start moves the cursor at the start.
-func (b *buffer) start() (err error) {
-	if b.pos == b.promptLen {
-		return
-	}
-
-	for ln, _ := b.pos2xy(b.pos); ln > 0; ln-- {
-		if _, err = term.Output.Write(CursorUp); err != nil {
+	if posColumn != 0 {
+		if _, err = fmt.Fprintf(b.out, "\033[%dC", posColumn); err != nil {
 			return outputError(err.Error())
 		}
 	}
 
-	if _, err = fmt.Fprintf(term.Output, "\r\033[%dC", b.promptLen); err != nil {
-		return outputError(err.Error())
-	}
-	b.pos = b.promptLen
-	return
+	return nil
 }
 
+// == Movement
+
 // end moves the cursor at the end.
 // Returns the number of lines that fill in the data.
 func (b *buffer) end() (lines int, err error) {
@@ -174,19 +276,10 @@ func (b *buffer) end() (lines int, err error) {
 		return
 	}
 
-	lastLine, lastColumn := b.pos2xy(b.size)
-
-	for ln, _ := b.pos2xy(b.pos); ln < lastLine; ln++ {
-		if _, err = term.Output.Write(CursorDown); err != nil {
-			return 0, outputError(err.Error())
-		}
-	}
-
-	if _, err = fmt.Fprintf(term.Output, "\r\033[%dC", lastColumn); err != nil {
-		return 0, outputError(err.Error())
-	}
+	oldPos := b.pos
 	b.pos = b.size
-	return lastLine, nil
+	lines, _ = b.pos2xy(b.size)
+	return lines, b.moveCursor(oldPos)
 }
 
 // backward moves the cursor one character backward.
@@ -195,22 +288,10 @@ func (b *buffer) backward() (start bool, err error) {
 	if b.pos == b.promptLen {
 		return true, nil
 	}
-	b.pos--
 
-	// If position is on the same line.
-	if _, col := b.pos2xy(b.pos); col != 0 {
-		if _, err = term.Output.Write(CursorBackward); err != nil {
-			return false, outputError(err.Error())
-		}
-	} else {
-		if _, err = term.Output.Write(CursorUp); err != nil {
-			return false, outputError(err.Error())
-		}
-		if _, err = fmt.Fprintf(term.Output, "\033[%dC", b.columns); err != nil {
-			return false, outputError(err.Error())
-		}
-	}
-	return
+	oldPos := b.pos
+	b.pos = b.prevPos(b.pos)
+	return false, b.moveCursor(oldPos)
 }
 
 // forward moves the cursor one character forward.
@@ -219,59 +300,150 @@ func (b *buffer) forward() (end bool, err error) {
 	if b.pos == b.size {
 		return true, nil
 	}
-	b.pos++
 
-	if _, col := b.pos2xy(b.pos); col != 0 {
-		if _, err = term.Output.Write(CursorForward); err != nil {
-			return false, outputError(err.Error())
-		}
-	} else {
-		if _, err = term.Output.Write(ToNextLine); err != nil {
-			return false, outputError(err.Error())
-		}
+	oldPos := b.pos
+	b.pos = b.nextPos(b.pos)
+	return false, b.moveCursor(oldPos)
+}
+
+// moveCursor moves the cursor in the terminal from the position oldPos to the
+// actual one.
+func (b *buffer) moveCursor(oldPos int) (err error) {
+	oldLine, oldColumn := b.pos2xy(oldPos)
+	line, column := b.pos2xy(b.pos)
+
+	// The brackets highlighted and the suggestion depend on the cursor.
+	if b.bracketStyle != "" || (b.suggester != nil && (oldPos == b.size || b.pos == b.size)) {
+		return b.redraw(oldLine)
 	}
-	return
+
+	if line < oldLine {
+		_, err = fmt.Fprintf(b.out, "\033[%dA", oldLine-line)
+	} else if line > oldLine {
+		_, err = fmt.Fprintf(b.out, "\033[%dB", line-oldLine)
+	}
+	if err != nil {
+		return outputError(err.Error())
+	}
+
+	if column < oldColumn {
+		_, err = fmt.Fprintf(b.out, "\033[%dD", oldColumn-column)
+	} else if column > oldColumn {
+		_, err = fmt.Fprintf(b.out, "\033[%dC", column-oldColumn)
+	}
+	if err != nil {
+		return outputError(err.Error())
+	}
+	return nil
 }
 
 // swap swaps the actual character by the previous one. If it is the end of the
 // line then it is swapped the 2nd previous by the previous one.
 func (b *buffer) swap() error {
-	if b.pos == b.promptLen {
+	if b.pos == b.promptLen || b.size-b.promptLen < 2 {
 		return nil
 	}
+	oldPos := b.pos
+	text := b.data[b.promptLen:b.size]
 
-	if b.pos < b.size {
-		aux := b.data[b.pos-1]
-		b.data[b.pos-1] = b.data[b.pos]
-		b.data[b.pos] = aux
-		b.pos++
-		// End of line
-	} else {
-		aux := b.data[b.pos-2]
-		b.data[b.pos-2] = b.data[b.pos-1]
-		b.data[b.pos-1] = aux
+	pos := b.pos - b.promptLen
+	if pos == len(text) { // End of line
+		pos = prevGrapheme(text, pos)
 	}
-	return b.refresh()
+	if pos == 0 {
+		return nil
+	}
+	prev, next := prevGrapheme(text, pos), pos+graphemeLen(text, pos)
+
+	swapped := append(append([]rune(nil), text[pos:next]...), text[prev:pos]...)
+	copy(text[prev:], swapped)
+	b.pos = b.promptLen + next
+	return b.refreshFrom(oldPos)
 }
 
-// wordBackward moves the cursor one word backward.
-func (b *buffer) wordBackward() (err error) {
-	for start := false; ; {
-		start, err = b.backward()
-		if start == true || err != nil || b.data[b.pos-1] == 32 {
-			return
+// wordBackward moves the cursor to the start of the word before of it.
+func (b *buffer) wordBackward() error {
+	return b.moveTo(b.wordStart(b.pos - b.promptLen))
+}
+
+// wordForward moves the cursor to the end of the word after of it.
+func (b *buffer) wordForward() error {
+	return b.moveTo(b.wordEnd(b.pos - b.promptLen))
+}
+
+// moveTo moves the cursor to the position pos, relative to the prompt.
+func (b *buffer) moveTo(pos int) error {
+	oldPos := b.pos
+	b.pos = b.promptLen + pos
+	return b.moveCursor(oldPos)
+}
+
+// moveLine moves the cursor to the previous or next line of the text, at the
+// column nearest to the actual one.
+// Returns a boolean to know if there is such line.
+func (b *buffer) moveLine(up bool) (moved bool, err error) {
+	text := b.data[b.promptLen:b.size]
+	pos := b.pos - b.promptLen
+	start, end := b.lineBounds(pos)
+
+	var lineStart, lineEnd int
+	if up {
+		if start == 0 {
+			return false, nil
 		}
+		lineStart, lineEnd = b.lineBounds(start - 1)
+	} else {
+		if end == len(text) {
+			return false, nil
+		}
+		lineStart, lineEnd = b.lineBounds(end + 1)
 	}
-}
 
-// wordForward moves the cursor one word forward.
-func (b *buffer) wordForward() (err error) {
-	for end := false; ; {
-		end, err = b.forward()
-		if end == true || err != nil || b.data[b.pos] == 32 {
-			return
+	width := stringWidth(string(text[start:pos]))
+	to := lineStart
+	for to < lineEnd {
+		n := graphemeLen(text[:lineEnd], to)
+		w := graphemeWidth(text[to : to+n])
+		if w > width {
+			break
 		}
+		width -= w
+		to += n
 	}
+	return true, b.moveTo(to)
+}
+
+// replace replaces the text between the positions start and end, relative to
+// the prompt, by the given runes, leaving the cursor after of them.
+func (b *buffer) replace(start, end int, runes []rune) error {
+	oldPos := b.pos
+	start += b.promptLen
+	end += b.promptLen
+
+	tail := append([]rune(nil), b.data[end:b.size]...)
+	b.grow(start + len(runes) + len(tail))
+	copy(b.data[start:], runes)
+	copy(b.data[start+len(runes):], tail)
+
+	b.size = start + len(runes) + len(tail)
+	b.pos = start + len(runes)
+	return b.refreshFrom(oldPos)
+}
+
+// set sets the prompt and the text of the line, with the cursor at the
+// position pos of the text.
+func (b *buffer) set(prompt, text []rune, pos int) error {
+	oldLine, _ := b.pos2xy(b.pos)
+
+	b.grow(len(prompt) + len(text))
+	copy(b.data, prompt)
+	copy(b.data[len(prompt):], text)
+
+	b.promptLen = len(prompt)
+	b.promptWidth = promptWidth(prompt)
+	b.size = len(prompt) + len(text)
+	b.pos = len(prompt) + pos
+	return b.redraw(oldLine)
 }
 
 // == Delete
@@ -282,11 +454,14 @@ func (b *buffer) deleteChar() (err error) {
 		return
 	}
 
-	copy(b.data[b.pos:], b.data[b.pos+1:b.size])
-	b.size--
+	n := b.nextPos(b.pos) - b.pos
+	width := graphemeWidth(b.data[b.pos : b.pos+n])
 
-	if lastLine, _ := b.pos2xy(b.size); lastLine == 0 {
-		if _, err = term.Output.Write(DelChar); err != nil {
+	copy(b.data[b.pos:], b.data[b.pos+n:b.size])
+	b.size -= n
+
+	if lastLine, _ := b.pos2xy(b.size); lastLine == 0 && width == 1 && !b.styled() {
+		if _, err = b.out.Write(DelChar); err != nil {
 			return outputError(err.Error())
 		}
 		return nil
@@ -300,17 +475,21 @@ func (b *buffer) deleteCharPrev() (err error) {
 		return
 	}
 
-	copy(b.data[b.pos-1:], b.data[b.pos:b.size])
-	b.pos--
-	b.size--
+	oldPos := b.pos
+	n := b.pos - b.prevPos(b.pos)
+	width := graphemeWidth(b.data[b.pos-n : b.pos])
+
+	copy(b.data[b.pos-n:], b.data[b.pos:b.size])
+	b.pos -= n
+	b.size -= n
 
-	if lastLine, _ := b.pos2xy(b.size); lastLine == 0 {
-		if _, err = term.Output.Write(DelBackspace); err != nil {
+	if lastLine, _ := b.pos2xy(b.size); lastLine == 0 && width == 1 && !b.styled() {
+		if _, err = b.out.Write(DelBackspace); err != nil {
 			return outputError(err.Error())
 		}
 		return nil
 	}
-	return b.refresh()
+	return b.refreshFrom(oldPos)
 }
 
 // deleteToRight deletes from current position until to end of line.
@@ -318,63 +497,151 @@ func (b *buffer) deleteToRight() (err error) {
 	if b.pos == b.size {
 		return
 	}
+	if b.styled() { // The styles of the text kept could change.
+		b.size = b.pos
+		return b.refresh()
+	}
 
 	lastLine, _ := b.pos2xy(b.size)
 	posLine, _ := b.pos2xy(b.pos)
 
 	// To the last line.
 	for ln := posLine; ln < lastLine; ln++ {
-		if _, err = term.Output.Write(CursorDown); err != nil {
+		if _, err = b.out.Write(CursorDown); err != nil {
 			return outputError(err.Error())
 		}
 	}
 	// Delete all lines until the cursor position.
 	for ln := lastLine; ln > posLine; ln-- {
-		if _, err = term.Output.Write(DelLine_cursorUp); err != nil {
+		if _, err = b.out.Write(DelLine_cursorUp); err != nil {
 			return outputError(err.Error())
 		}
 	}
 
-	if _, err = term.Output.Write(DelToRight); err != nil {
+	if _, err = b.out.Write(DelToRight); err != nil {
 		return outputError(err.Error())
 	}
 	b.size = b.pos
 	return nil
 }
 
-// deleteLine deletes full line.
-func (b *buffer) deleteLine() error {
-	lines, err := b.end()
-	if err != nil {
-		return err
+// == Utility
+
+// grow grows the buffer to guarantee space for n characters, reallocating it
+// whether its capacity is exceeded.
+func (b *buffer) grow(n int) {
+	if n <= len(b.data) {
+		return
+	}
+	if n <= cap(b.data) {
+		b.data = b.data[:n]
+		return
 	}
 
-	for lines > 0 {
-		if _, err = term.Output.Write(DelLine_cursorUp); err != nil {
-			return outputError(err.Error())
+	data := make([]rune, n, 2*n)
+	copy(data, b.data)
+	b.data = data
+}
+
+// wordStart returns the position, relative to the prompt, of the start of the
+// word before of the position pos. The words are separated by blanks.
+func (b *buffer) wordStart(pos int) int {
+	text := b.data[b.promptLen:b.size]
+	if pos > len(text) {
+		pos = len(text)
+	}
+
+	for inWord := false; pos > 0; {
+		prev := prevGrapheme(text, pos)
+		if unicode.IsSpace(text[prev]) {
+			if inWord {
+				break
+			}
+		} else {
+			inWord = true
 		}
-		lines--
+		pos = prev
 	}
-	return nil
+	return pos
 }
 
-// == Utility
+// wordEnd returns the position, relative to the prompt, of the end of the word
+// after of the position pos. The words are separated by blanks.
+func (b *buffer) wordEnd(pos int) int {
+	text := b.data[b.promptLen:b.size]
+	if pos < 0 {
+		pos = 0
+	}
 
-// grow grows buffer to guarantee space for n more byte.
-func (b *buffer) grow(n int) {
-	for n > len(b.data) {
-		b.data = b.data[:len(b.data)+BufferLen]
+	for inWord := false; pos < len(text); {
+		if unicode.IsSpace(text[pos]) {
+			if inWord {
+				break
+			}
+		} else {
+			inWord = true
+		}
+		pos += graphemeLen(text, pos)
 	}
+	return pos
+}
+
+// lineBounds returns the positions, relative to the prompt, of the start and
+// end of the line of the text which has the position pos.
+func (b *buffer) lineBounds(pos int) (start, end int) {
+	text := b.data[b.promptLen:b.size]
+
+	for start = pos; start > 0 && text[start-1] != '\n'; start-- {
+	}
+	for end = pos; end < len(text) && text[end] != '\n'; end++ {
+	}
+	return
+}
+
+// nextPos returns the position after of the character at the position pos,
+// together with the characters combined with it.
+func (b *buffer) nextPos(pos int) int {
+	return pos + graphemeLen(b.data[b.promptLen:b.size], pos-b.promptLen)
+}
+
+// prevPos returns the position of the character before of the position pos,
+// together with the characters combined with it.
+func (b *buffer) prevPos(pos int) int {
+	return b.promptLen + prevGrapheme(b.data[b.promptLen:b.size], pos-b.promptLen)
 }
 
 // pos2xy returns the coordinates of a position for a line of size given in
-// columns.
+// columns. The characters are placed according to the columns that they use,
+// so a wide character which does not fit at the end of a line is placed in
+// the next one, and the new lines of the text are placed after of the
+// continuation prompt. The positions into the prompt are at the start of the
+// line.
 func (b *buffer) pos2xy(pos int) (line, column int) {
-	if pos < b.columns {
-		return 0, pos
+	if pos < b.promptLen {
+		return 0, 0
+	}
+
+	column = b.promptWidth
+	if b.columns > 0 {
+		line, column = column/b.columns, column%b.columns
 	}
 
-	line = pos / b.columns
-	column = pos - (line * b.columns) //- 1
+	for i, n := b.promptLen, 0; i < pos; i += n {
+		n = graphemeLen(b.data[:pos], i)
+		if b.data[i] == '\n' {
+			line, column = line+1, b.contWidth
+			if b.columns > 0 {
+				line, column = line+column/b.columns, column%b.columns
+			}
+			continue
+		}
+		w := graphemeWidth(b.data[i : i+n])
+		if b.columns > 0 && column+w > b.columns {
+			line, column = line+1, 0
+		}
+		if column += w; b.columns > 0 && column == b.columns {
+			line, column = line+1, 0
+		}
+	}
 	return
 }
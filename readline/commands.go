@@ -0,0 +1,437 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"io"
+	"strings"
+
+	"github.com/tredoe/term/keys"
+)
+
+func init() {
+	for name, cmd := range map[string]Command{
+		"self-insert":  selfInsert,
+		"accept-line":  acceptLine,
+		"complete":     complete,
+		"interrupt":    interrupt,
+		"end-of-file":  endOfFile,
+		"abort":        abort,
+		"clear-screen": clearScreen,
+
+		"re-read-init-file": rereadInitFile,
+
+		"backward-char":     backwardChar,
+		"forward-char":      forwardChar,
+		"backward-word":     backwardWord,
+		"forward-word":      forwardWord,
+		"beginning-of-line": beginningOfLine,
+		"end-of-line":       endOfLine,
+
+		"previous-history":        previousHistory,
+		"next-history":            nextHistory,
+		"history-search-backward": historySearchBackward,
+		"history-search-forward":  historySearchForward,
+		"reverse-search-history":  reverseSearchHistory,
+		"forward-search-history":  forwardSearchHistory,
+
+		"backward-delete-char": backwardDeleteChar,
+		"delete-char":          deleteChar,
+		"transpose-chars":      transposeChars,
+
+		"kill-line":          killLine,
+		"kill-whole-line":    killWholeLine,
+		"unix-line-discard":  unixLineDiscard,
+		"unix-word-rubout":   unixWordRubout,
+		"backward-kill-word": unixWordRubout,
+		"kill-word":          killWord,
+		"yank":               yank,
+		"yank-pop":           yankPop,
+
+		"undo": undo,
+		"redo": redo,
+
+		"vi-editing-mode":    viEditingMode,
+		"emacs-editing-mode": emacsEditingMode,
+		"vi-movement-mode":   viMovementMode,
+		"vi-command":         viCommand,
+	} {
+		RegisterCommand(name, cmd)
+	}
+}
+
+// == Editing
+//
+
+// Text returns the text of the line being edited.
+func (ln *Line) Text() string {
+	return ln.buf.toString()
+}
+
+// Cursor returns the position of the cursor into the text, in characters.
+func (ln *Line) Cursor() int {
+	return ln.buf.pos - ln.buf.promptLen
+}
+
+// SetText replaces the text of the line, setting the cursor at the position
+// pos, in characters.
+func (ln *Line) SetText(text string, pos int) error {
+	runes := []rune(text)
+	if pos < 0 || pos > len(runes) {
+		pos = len(runes)
+	}
+	return ln.buf.set(ln.buf.prompt(), runes, pos)
+}
+
+// Insert inserts the text at the cursor position.
+func (ln *Line) Insert(text string) error {
+	pos := ln.Cursor()
+	return ln.buf.replace(pos, pos, []rune(text))
+}
+
+// Accept finishes the edition, so Read returns the text of the line once the
+// command running is finished.
+func (ln *Line) Accept() {
+	ln.accepted = true
+	ln.line = ln.buf.toString()
+}
+
+// == Commands
+//
+
+func selfInsert(ln *Line, key keys.Key) error {
+	ln.action = _INSERT
+	return ln.buf.insertRune(key.Rune)
+}
+
+func acceptLine(ln *Line, _ keys.Key) error {
+	if ln.validator != nil && !ln.validator.IsComplete(ln.buf.toString()) {
+		ln.action = _INSERT
+		return ln.buf.insertRune('\n')
+	}
+	if ln.histExpand && ln.useHistory {
+		if ok, err := ln.expandHistory(); !ok || err != nil {
+			return err
+		}
+	}
+	if _, err := ln.buf.end(); err != nil {
+		return err
+	}
+	if err := ln.buf.hideHints(); err != nil {
+		return err
+	}
+	ln.Accept()
+
+	if _, err := ln.ter.Output().Write(CRLF); err != nil {
+		return outputError(err.Error())
+	}
+	if ln.useHistory {
+		return ln.hist.Add(ln.line)
+	}
+	return nil
+}
+
+func complete(ln *Line, _ keys.Key) error {
+	if ln.completer == nil {
+		return nil
+	}
+
+	tabs := 1
+	if ln.last == _COMPLETE {
+		tabs = 2
+	}
+	ln.action = _COMPLETE
+	return ln.complete(tabs)
+}
+
+func interrupt(ln *Line, _ keys.Key) error {
+	if err := ln.echoControl(CtrlC); err != nil {
+		return err
+	}
+	if !ln.ignoreInterrupt {
+		return ErrInterrupt
+	}
+
+	// The text is discarded, like in Bash.
+	if err := ln.Prompt(); err != nil {
+		return err
+	}
+	ln.resetUndo()
+	if ln.useHistory {
+		ln.histPos, ln.scratch = ln.hist.Len(), ""
+	}
+	return nil
+}
+
+// endOfFile finishes the reading when the line is empty; else, it deletes the
+// character at the cursor.
+func endOfFile(ln *Line, key keys.Key) error {
+	if ln.buf.size != ln.buf.promptLen {
+		return deleteChar(ln, key)
+	}
+	if err := ln.echoControl(CtrlD); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// echoControl writes the control character at the end of the text, moving the
+// cursor to the next line.
+func (ln *Line) echoControl(char []rune) error {
+	if _, err := ln.buf.end(); err != nil {
+		return err
+	}
+	if err := ln.buf.hideHints(); err != nil {
+		return err
+	}
+	if _, err := ln.ter.Output().Write([]byte(string(char) + "\r\n")); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
+
+func abort(ln *Line, _ keys.Key) error {
+	return ln.bell()
+}
+
+func clearScreen(ln *Line, _ keys.Key) error {
+	if _, err := ln.ter.Output().Write(DelScreenToUpper); err != nil {
+		return outputError(err.Error())
+	}
+	return ln.writeAgain()
+}
+
+// == Movement
+
+func backwardChar(ln *Line, _ keys.Key) error {
+	_, err := ln.buf.backward()
+	return err
+}
+
+func forwardChar(ln *Line, _ keys.Key) error {
+	if ok, err := ln.buf.acceptSuggestion(false); ok || err != nil {
+		return err
+	}
+	_, err := ln.buf.forward()
+	return err
+}
+
+func backwardWord(ln *Line, _ keys.Key) error {
+	return ln.buf.wordBackward()
+}
+
+func forwardWord(ln *Line, _ keys.Key) error {
+	if ok, err := ln.buf.acceptSuggestion(true); ok || err != nil {
+		return err
+	}
+	return ln.buf.wordForward()
+}
+
+func beginningOfLine(ln *Line, _ keys.Key) error {
+	start, _ := ln.buf.lineBounds(ln.Cursor())
+	return ln.buf.moveTo(start)
+}
+
+func endOfLine(ln *Line, _ keys.Key) error {
+	if ok, err := ln.buf.acceptSuggestion(false); ok || err != nil {
+		return err
+	}
+	_, end := ln.buf.lineBounds(ln.Cursor())
+	return ln.buf.moveTo(end)
+}
+
+// == History
+
+// historyLine replaces the line by the one got from history.
+func historyLine(ln *Line, prev bool) error {
+	if !ln.useHistory {
+		return nil
+	}
+
+	pos := ln.histPos + 1
+	if prev {
+		pos = ln.histPos - 1
+	}
+	if pos < 0 || pos > ln.hist.Len() {
+		return nil
+	}
+
+	ln.setHistoryPos(pos, ln.buf.toString())
+
+	anotherLine := []rune(ln.scratch)
+	if pos < ln.hist.Len() {
+		anotherLine = []rune(ln.hist.At(pos).Line)
+	}
+	return ln.buf.set(ln.buf.prompt(), anotherLine, len(anotherLine))
+}
+
+// setHistoryPos sets the position of the history entry shown, keeping the text
+// edited before of moving in history, which is shown after of the last entry.
+func (ln *Line) setHistoryPos(pos int, text string) {
+	if ln.histPos == ln.hist.Len() {
+		ln.scratch = text
+	}
+	ln.histPos = pos
+}
+
+// historySearch replaces the line by the previous or next entry of history
+// which starts with the text before of the cursor, keeping the cursor after of
+// that text. The line edited before of moving in history is got after of the
+// last entry.
+func historySearch(ln *Line, backward bool) error {
+	if !ln.useHistory {
+		return nil
+	}
+
+	// The prefix is kept while the command is repeated.
+	if ln.last != _HISTORY_SEARCH {
+		ln.histPrefix = string(ln.buf.text(0, ln.Cursor()))
+	}
+	ln.action = _HISTORY_SEARCH
+	prefix := []rune(ln.histPrefix)
+	current := ln.buf.toString()
+
+	step := 1
+	if backward {
+		step = -1
+	}
+	for pos := ln.histPos + step; 0 <= pos && pos <= ln.hist.Len(); pos += step {
+		var line string
+		if pos < ln.hist.Len() {
+			if line = ln.hist.At(pos).Line; line == current ||
+				!strings.HasPrefix(line, ln.histPrefix) {
+				continue
+			}
+		}
+		ln.setHistoryPos(pos, current)
+		if pos == ln.hist.Len() {
+			line = ln.scratch
+		}
+
+		text := []rune(line)
+		cursor := len(prefix)
+		if cursor == 0 || cursor > len(text) {
+			cursor = len(text)
+		}
+		return ln.buf.set(ln.buf.prompt(), text, cursor)
+	}
+	return ln.bell()
+}
+
+func previousHistory(ln *Line, _ keys.Key) error {
+	if moved, err := ln.buf.moveLine(true); moved || err != nil {
+		return err
+	}
+	if ln.prefixSearch {
+		return historySearch(ln, true)
+	}
+	return historyLine(ln, true)
+}
+
+func nextHistory(ln *Line, _ keys.Key) error {
+	if moved, err := ln.buf.moveLine(false); moved || err != nil {
+		return err
+	}
+	if ln.prefixSearch {
+		return historySearch(ln, false)
+	}
+	return historyLine(ln, false)
+}
+
+func historySearchBackward(ln *Line, _ keys.Key) error {
+	return historySearch(ln, true)
+}
+
+func historySearchForward(ln *Line, _ keys.Key) error {
+	return historySearch(ln, false)
+}
+
+func reverseSearchHistory(ln *Line, _ keys.Key) error {
+	if !ln.useHistory {
+		return nil
+	}
+	return ln.search(true)
+}
+
+func forwardSearchHistory(ln *Line, _ keys.Key) error {
+	if !ln.useHistory {
+		return nil
+	}
+	return ln.search(false)
+}
+
+// == Deletion
+
+func backwardDeleteChar(ln *Line, _ keys.Key) error {
+	return ln.buf.deleteCharPrev()
+}
+
+func deleteChar(ln *Line, _ keys.Key) error {
+	return ln.buf.deleteChar()
+}
+
+func transposeChars(ln *Line, _ keys.Key) error {
+	return ln.buf.swap()
+}
+
+// == Kill and yank
+
+func killLine(ln *Line, _ keys.Key) error {
+	pos := ln.Cursor()
+	ln.killRing.add(ln.buf.text(pos, ln.buf.size-ln.buf.promptLen), ln.last == _KILL, false)
+	ln.action = _KILL
+	return ln.buf.deleteToRight()
+}
+
+func killWholeLine(ln *Line, _ keys.Key) error {
+	n := ln.buf.size - ln.buf.promptLen
+	ln.killRing.add(ln.buf.text(0, n), ln.last == _KILL, true)
+	ln.action = _KILL
+	return ln.buf.replace(0, n, nil)
+}
+
+func unixLineDiscard(ln *Line, _ keys.Key) error {
+	ln.action = _KILL
+	return ln.kill(0, ln.Cursor(), ln.last == _KILL)
+}
+
+func unixWordRubout(ln *Line, _ keys.Key) error {
+	pos := ln.Cursor()
+	ln.action = _KILL
+	return ln.kill(ln.buf.wordStart(pos), pos, ln.last == _KILL)
+}
+
+func killWord(ln *Line, _ keys.Key) error {
+	pos := ln.Cursor()
+	ln.action = _KILL
+	return ln.kill(pos, ln.buf.wordEnd(pos), ln.last == _KILL)
+}
+
+func yank(ln *Line, _ keys.Key) error {
+	ln.action = _YANK
+	return ln.yank()
+}
+
+func yankPop(ln *Line, _ keys.Key) error {
+	if ln.last != _YANK {
+		return nil
+	}
+	ln.action = _YANK
+	return ln.yankPop()
+}
+
+// == Undo
+
+func undo(ln *Line, _ keys.Key) error {
+	ln.action = _UNDO
+	return ln.undo()
+}
+
+func redo(ln *Line, _ keys.Key) error {
+	ln.action = _UNDO
+	return ln.redo()
+}
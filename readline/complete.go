@@ -0,0 +1,233 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tredoe/term/keys"
+)
+
+// CompletionQueryItems is the number of candidates, by default, from which it
+// is asked to the user whether they should be listed.
+var CompletionQueryItems = 100
+
+// A Completer returns the candidates to complete the text of a line.
+type Completer interface {
+	// Complete returns the candidates to complete the line whose cursor is at
+	// the position pos, besides of the positions start and end of the text to
+	// be replaced by a candidate. The positions are given in characters.
+	// A unique candidate is inserted followed by a space, unless it ends with
+	// a space or '/', like a directory.
+	Complete(line string, pos int) (candidates []string, start, end int)
+}
+
+// The CompleterFunc type is an adapter to allow the use of ordinary functions
+// as completers.
+type CompleterFunc func(line string, pos int) (candidates []string, start, end int)
+
+// Complete calls f(line, pos).
+func (f CompleterFunc) Complete(line string, pos int) ([]string, int, int) {
+	return f(line, pos)
+}
+
+// SetCompleter sets the completer used at pressing Tab.
+// If c is nil then the completion is disabled.
+func (ln *Line) SetCompleter(c Completer) {
+	ln.completer = c
+}
+
+// SetCompletionIgnoreCase sets whether the case is ignored at completing, so
+// the text can be replaced by candidates with a different case.
+func (ln *Line) SetCompletionIgnoreCase(ignore bool) {
+	ln.ignoreCase = ignore
+}
+
+// complete completes the text before of the cursor. The candidates are listed
+// when they have not a longer common prefix and Tab has been pressed twice.
+func (ln *Line) complete(tabs int) error {
+	text := []rune(ln.buf.toString())
+	pos := ln.buf.pos - ln.buf.promptLen
+
+	candidates, start, end := ln.completer.Complete(string(text), pos)
+	if start < 0 || start > end || end > len(text) {
+		return nil
+	}
+	if len(candidates) == 0 {
+		return ln.bell()
+	}
+
+	if len(candidates) == 1 {
+		return ln.buf.replace(start, end, []rune(withSuffix(candidates[0])))
+	}
+
+	prefix := commonPrefix(candidates, ln.ignoreCase)
+	n := utf8.RuneCountInString(prefix)
+	if n > end-start ||
+		(n == end-start && prefix != string(text[start:end])) {
+		return ln.buf.replace(start, end, []rune(prefix))
+	}
+	if tabs < 2 {
+		return ln.bell()
+	}
+	return ln.listCandidates(candidates)
+}
+
+// withSuffix returns the candidate followed by a space, so the next word can
+// be written, unless it ends with a space or '/'.
+func withSuffix(candidate string) string {
+	if candidate == "" || strings.HasSuffix(candidate, " ") || strings.HasSuffix(candidate, "/") {
+		return candidate
+	}
+	return candidate + " "
+}
+
+// listCandidates lists the candidates in columns, below of the line, with a
+// pager when they do not fit in the screen.
+func (ln *Line) listCandidates(candidates []string) (err error) {
+	out := ln.ter.Output()
+
+	if _, err = ln.buf.end(); err != nil {
+		return err
+	}
+	if _, err = out.Write(CRLF); err != nil {
+		return outputError(err.Error())
+	}
+
+	if len(candidates) > CompletionQueryItems {
+		fmt.Fprintf(out, "Display all %d possibilities? (y or n)", len(candidates))
+		ok, err := ln.askYesNo()
+		if err != nil {
+			return err
+		}
+		if _, err = out.Write(CRLF); err != nil {
+			return outputError(err.Error())
+		}
+		if !ok {
+			return ln.writeAgain()
+		}
+	}
+
+	// Candidates sorted vertically.
+	width := 0
+	for _, c := range candidates {
+		if n := stringWidth(c); n > width {
+			width = n
+		}
+	}
+	width += 2
+
+	cols := ln.buf.columns / width
+	if cols == 0 {
+		cols = 1
+	}
+	rows := (len(candidates) + cols - 1) / cols
+
+	height, _, err := ln.ter.GetSize()
+	if err != nil || height < 2 {
+		height = rows + 1
+	}
+
+	for row, shown := 0, 0; row < rows; row, shown = row+1, shown+1 {
+		if shown == height-1 {
+			if shown, err = ln.more(height); err != nil {
+				return err
+			}
+			if shown < 0 {
+				break
+			}
+		}
+
+		line := make([]string, 0, cols)
+		for col := 0; col < cols; col++ {
+			i := col*rows + row
+			if i >= len(candidates) {
+				break
+			}
+			c := candidates[i]
+			if col+1 < cols && i+rows < len(candidates) {
+				c += strings.Repeat(" ", width-stringWidth(c))
+			}
+			line = append(line, c)
+		}
+		if _, err = fmt.Fprint(out, strings.Join(line, ""), "\r\n"); err != nil {
+			return outputError(err.Error())
+		}
+	}
+
+	return ln.writeAgain()
+}
+
+// more shows the prompt of the pager, and returns the number of lines already
+// shown after of the key pressed: 0 to show a new page, height-2 to show only
+// one line more, or -1 to stop.
+func (ln *Line) more(height int) (shown int, err error) {
+	out := ln.ter.Output()
+
+	if _, err = fmt.Fprint(out, "--More--"); err != nil {
+		return 0, outputError(err.Error())
+	}
+	key, err := ln.readKey()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = out.Write(DelLine_CR); err != nil {
+		return 0, outputError(err.Error())
+	}
+
+	switch key {
+	case keys.Key{Code: keys.Rune, Rune: ' '}, keys.Key{Code: keys.Rune, Rune: 'y'}:
+		return 0, nil
+	case keys.Key{Code: keys.Enter}, ctrl('j'):
+		return height - 2, nil
+	}
+	return -1, nil
+}
+
+// askYesNo waits until it is pressed 'y' or 'n'.
+func (ln *Line) askYesNo() (bool, error) {
+	for {
+		key, err := ln.readKey()
+		if err != nil {
+			return false, err
+		}
+
+		switch key {
+		case keys.Key{Code: keys.Rune, Rune: 'y'}, keys.Key{Code: keys.Rune, Rune: 'Y'},
+			keys.Key{Code: keys.Rune, Rune: ' '}:
+			return true, nil
+		case keys.Key{Code: keys.Rune, Rune: 'n'}, keys.Key{Code: keys.Rune, Rune: 'N'},
+			keys.Key{Code: keys.Escape}, ctrl('c'), ctrl('g'):
+			return false, nil
+		}
+	}
+}
+
+// commonPrefix returns the longest prefix common to all strings. If ignoreCase
+// is true, the prefix is got with the case of the first string.
+func commonPrefix(s []string, ignoreCase bool) string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	prefix := []rune(s[0])
+	for _, v := range s[1:] {
+		i := 0
+		for _, r := range v {
+			if i == len(prefix) || !(prefix[i] == r ||
+				ignoreCase && unicode.ToLower(prefix[i]) == unicode.ToLower(r)) {
+				break
+			}
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	return string(prefix)
+}
@@ -0,0 +1,48 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import "testing"
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		in         []string
+		ignoreCase bool
+		prefix     string
+	}{
+		{nil, false, ""},
+		{[]string{"help"}, false, "help"},
+		{[]string{"hello", "help", "helium"}, false, "hel"},
+		{[]string{"año", "añil"}, false, "añ"},
+		{[]string{"abc", "xyz"}, false, ""},
+		{[]string{"Hello", "help"}, false, ""},
+		{[]string{"Hello", "help"}, true, "Hel"},
+		{[]string{"AÑO", "añil"}, true, "AÑ"},
+	}
+	for _, tt := range tests {
+		if p := commonPrefix(tt.in, tt.ignoreCase); p != tt.prefix {
+			t.Errorf("%q: expected %q, got %q", tt.in, tt.prefix, p)
+		}
+	}
+}
+
+func TestWithSuffix(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"hello", "hello "},
+		{"año", "año "},
+		{"dir/", "dir/"},
+		{"done ", "done "},
+	}
+	for _, tt := range tests {
+		if s := withSuffix(tt.in); s != tt.out {
+			t.Errorf("%q: expected %q, got %q", tt.in, tt.out, s)
+		}
+	}
+}
@@ -10,10 +10,16 @@ Package readline provides simple functions for both line and screen editing.
 Features:
 
    Unicode support
-   History
-   Multi-line editing
+   History, which can be shared by several processes
+   Completion
+   Kill ring
+   Undo and redo
+   Multi-line editing, wrapped again when the window is resized
+   Key bindings
+   Vi mode
+   Init file, like "~/.inputrc" in GNU Readline
 
-List of key sequences enabled (just like in GNU Readline):
+List of key sequences bound by default (just like in GNU Readline):
 
    Backspace / Ctrl+h
 
@@ -25,16 +31,81 @@ List of key sequences enabled (just like in GNU Readline):
    Right arrow / Ctrl+f
    Up arrow    / Ctrl+p
    Down arrow  / Ctrl+n
-   Ctrl+left arrow
-   Ctrl+right arrow
+            (they can recall only the lines which start with the text before
+            of the cursor, see Line.SetHistoryPrefixSearch)
+   Ctrl+left arrow  / Alt+b
+   Ctrl+right arrow / Alt+f
+
+   Tab    : complete the text before of the cursor, listing the candidates
+            when it is pressed twice (see Line.SetCompleter)
+
+   Ctrl+r : search backward in history, incrementally
+   Ctrl+s : search forward in history, incrementally
+            (Ctrl+g aborts the search, and Escape or a movement key accepts it)
 
    Ctrl+t : swap actual character by the previous one
-   Ctrl+k : delete from current to end of line
-   Ctrl+u : delete the whole line
+   Ctrl+k : kill from current to end of line
+   Ctrl+u : kill the whole line
+   Ctrl+w / Alt+Backspace : kill the previous word
+   Alt+d  : kill the next word
+   Ctrl+y : yank the last text killed
+   Alt+y  : rotate the kill ring, after of a yank
+
+   Ctrl+_ / Ctrl+x Ctrl+u : undo the last change
+   Ctrl+^ : redo the last change undone
    Ctrl+l : clear screen
+   Ctrl+x Ctrl+r : read again the init file (see Line.ReadInitFile)
+
+   Ctrl+c : interrupt, returning ErrInterrupt (see Line.SetIgnoreInterrupt)
+   Ctrl+d : delete the character at the cursor, or return io.EOF whether the
+            line is empty
+
+The key sequences are bound to commands through a Keymap, which can be changed
+by Line.SetKeymap; the commands are registered by name (see Commands), and new
+ones can be added through RegisterCommand.
+
+The lines read are added to a History, which keeps for every one the time, the
+working directory, the exit status (see History.SetStatus) and the session;
+it can be queried by prefix, substring, time range, or the last lines. The
+entries are saved to a HistoryStore: a text file (NewFileStore), the memory
+(NewMemoryStore), or a file in format JSON Lines (NewJSONLStore).
+
+The references to the history, like "!!", "!$" or "^old^new", can be expanded
+in the lines accepted, like in Bash (see Line.SetHistoryExpansion and
+ExpandHistory); with Line.SetHistoryVerify, the line expanded is shown to be
+edited before of accepting it.
+
+The text can be shown with styles through a Highlighter (see
+Line.SetHighlighter), which returns the parts of the text to show with every
+style, and the brackets matched can be highlighted too (see
+Line.SetBracketHighlight).
 
-   Ctrl+c
-   Ctrl+d : exit
+While the text is written, the rest of a line suggested, like the last one of
+the history which starts with the text (see HistorySuggester), can be shown
+dimmed after of the cursor (see Line.SetSuggester); it is accepted with Right
+or End, or word by word with Alt+f.
+
+The text can be written in several lines through a Validator (see
+Line.SetValidator): when Enter is pressed and the text is not complete, a new
+line is inserted, shown after of the secondary prompt. Then, the up and down
+arrows move the cursor between the lines of the text, before of moving in
+history, and Home and End move it into the line.
+
+The vi mode is set through Line.SetMode, starting every line in insert mode;
+Escape changes to the command mode, which supports the motions "h l w W b B e
+E 0 ^ $ | f F t T ; ,", the operators "d c y" (which can be doubled to apply
+them to the whole line), counts, the commands "x X s S D C Y p P r ~ i a I A",
+"u" to undo, "." to repeat the last change, "k j" to move in history, and
+"/ ? n N" to search in history. The mode can be shown in the prompt through
+Line.SetModePrompt.
+
+The reading can be aborted from another goroutine through the context given
+to Line.ReadContext, which also returns ErrIdleTimeout when no key is pressed
+during the time set by Line.SetIdleTimeout.
+
+The messages of other goroutines can be written above of the line being read
+through Line.Printf, or using the line like an io.Writer, so the line is
+written again below of them; they can be written from a command too.
 
 Note that There are several default values:
 
@@ -42,9 +113,21 @@ Note that There are several default values:
 
 + For the history file: HistoryCap, HistoryPerm.
 
++ For the completion: CompletionQueryItems.
+
++ For the kill ring: KillRingCap.
+
++ For the suggestions: SuggestionStyle.
+
+The prompts can have ANSI escape sequences, like colors, and several lines;
+their width is got skipping the escape sequences, and the characters between
+the markers \001 and \002, like in GNU Readline.
+
 Important: the TTY is set in "raw mode" so there is to use CR+LF ("\r\n") for
 writing a new line.
 
-Note: the values for the input and output are got from the package base "term".
+Note: the input and output are got from the terminal used by the line, which
+uses the values by default of the package base "term" when it is created
+through term.New.
 */
 package readline
@@ -8,7 +8,16 @@ package readline
 
 import "errors"
 
-var ErrCtrlD = errors.New("Interrumpted (Ctrl+d)")
+// ErrInterrupt is returned by Read at pressing Ctrl+c, together with the text
+// written (see Line.SetIgnoreInterrupt).
+var ErrInterrupt = errors.New("interrupted (Ctrl+c)")
+
+// ErrIdleTimeout is returned by ReadContext when no key is pressed during the
+// idle timeout.
+var ErrIdleTimeout = errors.New("idle timeout")
+
+// ErrEmptySeq is returned at binding or parsing an empty key sequence.
+var ErrEmptySeq = errors.New("keymap: empty key sequence")
 
 // An inputError represents a failure on input.
 type inputError string
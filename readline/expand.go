@@ -0,0 +1,306 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// An expansionError represents a failure expanding a reference to the history.
+type expansionError struct {
+	ref string // Reference which could not be expanded
+	msg string
+}
+
+func (e expansionError) Error() string {
+	return e.ref + ": " + e.msg
+}
+
+// SetHistoryExpansion sets whether the references to the history in the lines
+// accepted are expanded, like in Bash (see ExpandHistory).
+func (ln *Line) SetHistoryExpansion(enabled bool) {
+	ln.histExpand = enabled
+}
+
+// SetHistoryVerify sets whether a line whose references to the history have
+// been expanded is shown to be edited, instead of being accepted.
+func (ln *Line) SetHistoryVerify(verify bool) {
+	ln.histVerify = verify
+}
+
+// expandHistory expands the references to the history in the line. It reports
+// whether the line can be accepted, which is not when the expansion fails, nor
+// when the line expanded has to be verified.
+func (ln *Line) expandHistory() (bool, error) {
+	text := ln.buf.toString()
+
+	expanded, err := ExpandHistory(ln.hist, text)
+	if err != nil {
+		return false, ln.showMessage(err.Error())
+	}
+	if expanded == text {
+		return true, nil
+	}
+
+	runes := []rune(expanded)
+	if err = ln.buf.set(ln.buf.prompt(), runes, len(runes)); err != nil {
+		return false, err
+	}
+	return !ln.histVerify, nil
+}
+
+// showMessage writes the message below of the line, which is written again
+// after of it.
+func (ln *Line) showMessage(msg string) error {
+	if _, err := ln.buf.end(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(ln.ter.Output(), "\r\n%s\r\n", msg); err != nil {
+		return outputError(err.Error())
+	}
+	return ln.writeAgain()
+}
+
+// ExpandHistory expands the references to the entries of the history in line,
+// like in Bash. An event designator selects an entry:
+//
+//	!!          the last entry
+//	!n          the entry n, starting from 1 at the oldest one
+//	!-n         the entry n before of the actual line
+//	!string     the last entry which starts with string
+//	!?string?   the last entry which contains string
+//
+// which can be followed by a word designator, separated by ':', to select some
+// of its words, starting at 0: a number, "^" (the first argument), "$" (the
+// last argument), "*" (all the arguments), "x-y", "x*" and "x-" (all but the
+// last word). The colon can be omitted before of "^", "$" and "*"; then, "!"
+// can be used instead of "!!", like in "!$".
+//
+// A line which starts with "^old^new" is the last entry with the first
+// occurrence of old replaced by new.
+//
+// The character "!" is not expanded when it is followed by a blank, "=" or
+// "(", into single quotes, or when it is escaped by a backslash.
+func ExpandHistory(hist History, line string) (string, error) {
+	if strings.HasPrefix(line, "^") {
+		return quickSubstitution(hist, line)
+	}
+
+	var out strings.Builder
+	single, double := false, false // Into quotes
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case c == '\\' && !single && i+1 < len(line):
+			out.WriteString(line[i : i+2])
+			i++
+			continue
+		case c == '\'' && !double:
+			single = !single
+		case c == '"' && !single:
+			double = !double
+		case c == '!' && !single && i+1 < len(line) &&
+			!strings.ContainsRune(" \t\n=(\"", rune(line[i+1])):
+			text, n, err := expandEvent(hist, line[i:])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(text)
+			i += n - 1
+			continue
+		}
+		out.WriteByte(c)
+	}
+	return out.String(), nil
+}
+
+// quickSubstitution expands a line "^old^new^rest".
+func quickSubstitution(hist History, line string) (string, error) {
+	fields := strings.SplitN(line[1:], "^", 3)
+	old, new, rest := fields[0], "", ""
+	if len(fields) > 1 {
+		new = fields[1]
+	}
+	if len(fields) > 2 {
+		rest = fields[2]
+	}
+
+	if hist.Len() == 0 {
+		return "", expansionError{"!!", "event not found"}
+	}
+	last := hist.At(hist.Len() - 1).Line
+	if old == "" || !strings.Contains(last, old) {
+		return "", expansionError{line, "substitution failed"}
+	}
+	return strings.Replace(last, old, new, 1) + rest, nil
+}
+
+// expandEvent expands the reference to an entry at the start of s, returning
+// the text expanded and the number of bytes of the reference.
+func expandEvent(hist History, s string) (text string, n int, err error) {
+	var pos int // Position of the entry
+	n = 1
+
+	switch c := s[1]; {
+	case c == '!':
+		pos, n = hist.Len()-1, 2
+	case c == '^' || c == '$' || c == '*':
+		pos = hist.Len() - 1
+	case c == '-' || '0' <= c && c <= '9':
+		n = 2
+		for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+			n++
+		}
+		num, err := strconv.Atoi(s[1:n])
+		if err != nil || num == 0 {
+			return "", 0, expansionError{s[:n], "event not found"}
+		}
+		if pos = num - 1; num < 0 {
+			pos = hist.Len() + num
+		}
+	case c == '?':
+		end := strings.IndexByte(s[2:], '?')
+		query := s[2:]
+		if n = len(s); end != -1 {
+			query, n = s[2:2+end], 2+end+1
+		}
+		pos = findLast(hist, func(line string) bool { return strings.Contains(line, query) })
+	default:
+		for n < len(s) && !unicode.IsSpace(rune(s[n])) && s[n] != ':' {
+			n++
+		}
+		prefix := s[1:n]
+		pos = findLast(hist, func(line string) bool { return strings.HasPrefix(line, prefix) })
+	}
+
+	if pos < 0 || pos >= hist.Len() {
+		return "", 0, expansionError{s[:n], "event not found"}
+	}
+	text = hist.At(pos).Line
+
+	// Word designator
+	start := n
+	if n < len(s) && s[n] == ':' && n+1 < len(s) && strings.IndexByte("0123456789^$*-", s[n+1]) != -1 {
+		start = n + 1
+	} else if n == len(s) || strings.IndexByte("^$*", s[n]) == -1 {
+		return text, n, nil
+	}
+
+	text, end, ok := selectWords(text, s[start:])
+	if !ok {
+		return "", 0, expansionError{s[:start+end], "bad word specifier"}
+	}
+	return text, start + end, nil
+}
+
+// findLast returns the position of the last entry of the history whose line
+// matches, or -1.
+func findLast(hist History, match func(line string) bool) int {
+	for i := hist.Len() - 1; i >= 0; i-- {
+		if match(hist.At(i).Line) {
+			return i
+		}
+	}
+	return -1
+}
+
+// selectWords returns the words of line selected by the word designator at the
+// start of s, and the number of bytes of the designator.
+func selectWords(line, s string) (text string, n int, ok bool) {
+	words := splitWords(line)
+	last := len(words) - 1
+
+	// number returns the number at the start of s[n:], or the last word for "$".
+	number := func() (int, bool) {
+		if n < len(s) && s[n] == '$' {
+			n++
+			return last, true
+		}
+		i := n
+		for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+			n++
+		}
+		num, err := strconv.Atoi(s[i:n])
+		return num, err == nil
+	}
+
+	var from, to int
+	switch s[0] {
+	case '^':
+		from, to, n = 1, 1, 1
+	case '*':
+		if last < 1 {
+			return "", 1, true
+		}
+		from, to, n = 1, last, 1
+	case '-':
+		n = 1
+		if to, ok = number(); !ok {
+			return "", n, false
+		}
+	default:
+		if from, ok = number(); !ok {
+			return "", n, false
+		}
+		to = from
+
+		if n < len(s) && s[n] == '*' {
+			n++
+			if to = last; from > last {
+				return "", n, true
+			}
+		} else if n < len(s) && s[n] == '-' {
+			n++
+			if n < len(s) && (s[n] == '$' || '0' <= s[n] && s[n] <= '9') {
+				to, _ = number()
+			} else {
+				to = last - 1
+			}
+		}
+	}
+
+	if from < 0 || from > to || to > last {
+		return "", n, false
+	}
+	return strings.Join(words[from:to+1], " "), n, true
+}
+
+// splitWords splits the line in words separated by blanks, except into quotes.
+func splitWords(line string) []string {
+	var words []string
+	var quote rune
+	start := -1
+
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case unicode.IsSpace(r):
+			if start != -1 {
+				words = append(words, line[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start == -1 {
+			start = i
+		}
+	}
+	if start != -1 {
+		words = append(words, line[start:])
+	}
+	return words
+}
@@ -0,0 +1,72 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import "testing"
+
+func TestExpandHistory(t *testing.T) {
+	hist, err := NewHistoryOfStore(NewMemoryStore(), HistoryCap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"ls -l /tmp", "echo 'a b' c", "cp x y z"} {
+		hist.Add(s)
+	}
+
+	tests := []struct {
+		line     string
+		expanded string
+		err      string
+	}{
+		{"!!", "cp x y z", ""},
+		{"sudo !!", "sudo cp x y z", ""},
+		{"!1", "ls -l /tmp", ""},
+		{"!-2", "echo 'a b' c", ""},
+		{"!ls", "ls -l /tmp", ""},
+		{"!?a b?", "echo 'a b' c", ""},
+		{"!?/tmp", "ls -l /tmp", ""},
+		{"cat !$", "cat z", ""},
+		{"!^", "x", ""},
+		{"mv !*", "mv x y z", ""},
+		{"!echo:1", "'a b'", ""},
+		{"!!:0-1", "cp x", ""},
+		{"!!:2*", "y z", ""},
+		{"!!:1-", "x y", ""},
+		{"!!:-2", "cp x y", ""},
+		{"!1:$.bak", "/tmp.bak", ""},
+		{"^x^w", "cp w y z", ""},
+		{"^y^^ end", "cp x  z end", ""},
+
+		// Not expanded.
+		{"echo hi!", "echo hi!", ""},
+		{"a ! b", "a ! b", ""},
+		{"x != y", "x != y", ""},
+		{"echo '!!'", "echo '!!'", ""},
+		{`echo \!!`, `echo \!!`, ""},
+		{`echo "!!"`, `echo "cp x y z"`, ""},
+
+		// Errors.
+		{"!9", "", "!9: event not found"},
+		{"!nothing", "", "!nothing: event not found"},
+		{"!!:7", "", "!!:7: bad word specifier"},
+		{"^no^yes", "", "^no^yes: substitution failed"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandHistory(hist, tt.line)
+		if err != nil {
+			if err.Error() != tt.err {
+				t.Errorf("%q: expected error %q, got %q", tt.line, tt.err, err)
+			}
+			continue
+		}
+		if tt.err != "" {
+			t.Errorf("%q: expected error %q, got %q", tt.line, tt.err, got)
+		} else if got != tt.expanded {
+			t.Errorf("%q: expected %q, got %q", tt.line, tt.expanded, got)
+		}
+	}
+}
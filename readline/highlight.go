@@ -0,0 +1,157 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import "sort"
+
+// A Span represents a part of the text, between the positions Start and End
+// (not included) given in bytes, shown with the attributes of Style.
+// The style is the list of parameters of the sequence SGR, like "1;31" for
+// bold and red.
+type Span struct {
+	Start, End int
+	Style      string
+}
+
+// A Highlighter returns the styles of the text of a line.
+type Highlighter interface {
+	// Highlight returns the parts of the text to show with a style. The parts
+	// which are overlapped are shown with the style of the last one.
+	Highlight(text string) []Span
+}
+
+// The HighlighterFunc type is an adapter to allow the use of ordinary
+// functions as highlighters.
+type HighlighterFunc func(text string) []Span
+
+// Highlight calls f(text).
+func (f HighlighterFunc) Highlight(text string) []Span {
+	return f(text)
+}
+
+// SetHighlighter sets the highlighter used every time the line is written.
+// If h is nil then the text is shown without styles.
+func (ln *Line) SetHighlighter(h Highlighter) {
+	ln.buf.highlighter = h
+}
+
+// SetBracketHighlight sets the style of the brackets "()[]{}" at the cursor, or
+// just before of it, and of its matching bracket, like "7" for reverse video.
+// If style is empty then the brackets are not highlighted.
+func (ln *Line) SetBracketHighlight(style string) {
+	ln.buf.bracketStyle = style
+}
+
+// styled reports whether the text is written with styles or a suggestion, so
+// the line has to be written again at every change.
+func (b *buffer) styled() bool {
+	return b.highlighter != nil || b.bracketStyle != "" || b.suggester != nil
+}
+
+// styles returns the style of every character of the text, followed by the
+// ones of the suggestion.
+func (b *buffer) styles() []string {
+	text := b.data[b.promptLen:b.size]
+	styles := make([]string, len(text), len(text)+len(b.ghost))
+
+	if b.highlighter != nil {
+		str := string(text)
+
+		// The byte offsets of the spans are converted to characters.
+		offsets := make([]int, 0, len(text))
+		for i := range str {
+			offsets = append(offsets, i)
+		}
+		for _, sp := range b.highlighter.Highlight(str) {
+			end := sort.SearchInts(offsets, sp.End)
+			for i := sort.SearchInts(offsets, sp.Start); i < end; i++ {
+				styles[i] = sp.Style
+			}
+		}
+	}
+
+	if b.bracketStyle != "" {
+		if i, j := b.matchBracket(); i != -1 {
+			for _, k := range []int{i, j} {
+				if styles[k] != "" {
+					styles[k] += ";"
+				}
+				styles[k] += b.bracketStyle
+			}
+		}
+	}
+
+	for range b.ghost {
+		styles = append(styles, SuggestionStyle)
+	}
+	return styles
+}
+
+// matchBracket returns the positions, relative to the prompt, of the bracket
+// at the cursor, or else just before of it, and of its matching bracket.
+// They are -1 if there is not a bracket or it is not matched.
+func (b *buffer) matchBracket() (int, int) {
+	text := b.data[b.promptLen:b.size]
+	pos := b.pos - b.promptLen
+
+	for _, i := range []int{pos, pos - 1} {
+		if i < 0 || i >= len(text) {
+			continue
+		}
+		if j := findMatching(text, i); j != -1 {
+			return i, j
+		}
+	}
+	return -1, -1
+}
+
+// findMatching returns the position of the bracket which matches the one at
+// the position pos, or -1.
+func findMatching(text []rune, pos int) int {
+	bracket, match, step := text[pos], closing[text[pos]], 1
+	if match == 0 { // A closing bracket is matched backward.
+		for open, close := range closing {
+			if close == bracket {
+				match, step = open, -1
+			}
+		}
+		if match == 0 {
+			return -1
+		}
+	}
+
+	depth := 0
+	for i := pos; 0 <= i && i < len(text); i += step {
+		switch text[i] {
+		case bracket:
+			depth++
+		case match:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// hideHints writes the line without the brackets highlighted nor the
+// suggestion, like at accepting it, so they are not kept in the screen.
+func (b *buffer) hideHints() error {
+	matched := false
+	if b.bracketStyle != "" {
+		i, _ := b.matchBracket()
+		matched = i != -1
+	}
+	if !matched && len(b.ghost) == 0 {
+		return nil
+	}
+
+	style, suggester := b.bracketStyle, b.suggester
+	b.bracketStyle, b.suggester = "", nil
+	defer func() { b.bracketStyle, b.suggester = style, suggester }()
+	return b.refresh()
+}
@@ -0,0 +1,96 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// highlightWords shows the first word in bold and the rest in blue.
+func highlightWords(text string) []Span {
+	spans := []Span{{0, len(text), "34"}}
+	if i := strings.IndexByte(text, ' '); i != -1 {
+		spans = append(spans, Span{0, i, "1"})
+	}
+	return spans
+}
+
+func TestHighlight(t *testing.T) {
+	b := newBuffer(ioutil.Discard, 80)
+	b.reset([]rune("$ "))
+	b.setContPrompt([]rune("> "))
+	b.highlighter = HighlighterFunc(highlightWords)
+	b.insertRunes([]rune("ls a\nb"))
+
+	want := "$ \033[1mls\033[0m\033[34m a\033[0m\033[0K\r\n> \033[34mb\033[0m"
+	if got := string(b.toBytes()); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// The styles do not change the cursor.
+	if line, column := b.pos2xy(b.size); line != 1 || column != 3 {
+		t.Errorf("expected (1, 3), got (%d, %d)", line, column)
+	}
+
+	// The whole line is written at inserting.
+	var out bytes.Buffer
+	b.out = &out
+	b.insertRune('c')
+	if !bytes.Contains(out.Bytes(), []byte("\033[34mbc\033[0m")) {
+		t.Errorf("expected line written with styles, got %q", out.Bytes())
+	}
+
+	// The positions are given in bytes.
+	b = newBuffer(ioutil.Discard, 80)
+	b.reset([]rune("$ "))
+	b.highlighter = HighlighterFunc(highlightWords)
+	b.insertRunes([]rune("año ñu"))
+
+	want = "$ \033[1maño\033[0m\033[34m ñu\033[0m"
+	if got := string(b.toBytes()); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestMatchBracket(t *testing.T) {
+	tests := []struct {
+		text string
+		pos  int
+		i, j int
+	}{
+		{"f(a[1])", 1, 1, 6},
+		{"f(a[1])", 7, 6, 1}, // before of the cursor
+		{"f(a[1])", 3, 3, 5},
+		{"f(a[1])", 4, 3, 5},
+		{"f(a", 1, -1, -1}, // not matched
+		{"abc", 1, -1, -1},
+	}
+	b := newBuffer(ioutil.Discard, 80)
+	b.bracketStyle = "7"
+
+	for _, tt := range tests {
+		b.reset([]rune("$ "))
+		b.insertRunes([]rune(tt.text))
+		b.pos = b.promptLen + tt.pos
+
+		if i, j := b.matchBracket(); i != tt.i || j != tt.j {
+			t.Errorf("%q at %d: expected (%d, %d), got (%d, %d)", tt.text, tt.pos, tt.i, tt.j, i, j)
+		}
+	}
+
+	// The style of the brackets is added to the one of the text.
+	b.reset([]rune("$ "))
+	b.insertRunes([]rune("(a b)"))
+	b.pos = b.promptLen
+	b.highlighter = HighlighterFunc(highlightWords)
+	if got := b.styles(); strings.Join(got, ",") != "1;7,1,34,34,34;7" {
+		t.Errorf("unexpected styles %q", got)
+	}
+}
@@ -7,14 +7,11 @@
 package readline
 
 import (
-	"bufio"
-	"container/list"
 	"errors"
-	"io"
-	"log"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Values by default
@@ -23,174 +20,300 @@ var (
 	HistoryPerm os.FileMode = 0600 // History file permission
 )
 
-var (
-	ErrEmptyHist  = errors.New("history: empty")
-	ErrNilElement = errors.New("history: no more elements")
+// A DupsPolicy represents how are handled the lines equal to others in the
+// history.
+type DupsPolicy int
+
+const (
+	KeepDups   DupsPolicy = iota // All lines are added; the policy by default
+	IgnoreDups                   // A line equal to the last one is not added
+	EraseDups                    // The entries equal to a new line are removed
 )
 
 // == Type
 
-type history struct {
-	Cap      int
-	filename string
-	file     *os.File
-	mark     *list.Element // Pointer to the last element added.
-	li       *list.List
+// An Entry represents a line of the history, with information about its
+// execution.
+type Entry struct {
+	Line    string    `json:"line"`
+	Time    time.Time `json:"time"`              // When it was added; zero if it is unknown
+	Dir     string    `json:"dir,omitempty"`     // Working directory; empty if it is unknown
+	Status  int       `json:"status"`            // Exit status; -1 if it is unknown
+	Session string    `json:"session,omitempty"` // Identifier of the session which added it
 }
 
-// _baseHistory is the base to create an history file.
-func _baseHistory(fname string, size int) (*history, error) {
-	file, err := os.OpenFile(fname, os.O_CREATE|os.O_RDWR, HistoryPerm)
-	if err != nil {
-		return nil, err
-	}
+// A History represents the lines read, from the oldest to the newest one.
+type History interface {
+	// Add adds a new line, run in the actual session.
+	Add(line string) error
+
+	// SetStatus sets the exit status of the last line added in the session.
+	SetStatus(status int) error
+
+	// Len returns the number of entries.
+	Len() int
+
+	// At returns the entry at the position i, being 0 the oldest one.
+	At(i int) Entry
 
-	h := new(history)
+	// HasPrefix returns the entries which start with prefix.
+	HasPrefix(prefix string) []Entry
+
+	// Contains returns the entries which contain substr.
+	Contains(substr string) []Entry
+
+	// Between returns the entries added since the time from, until before of
+	// the time to.
+	Between(from, to time.Time) []Entry
+
+	// Last returns the last n entries, or all whether there are less; none if
+	// n is not positive.
+	Last(n int) []Entry
+}
+
+// A StoredHistory is a History whose entries are saved to a HistoryStore.
+type StoredHistory struct {
+	Cap     int // Maximum number of entries
+	dups    DupsPolicy
+	session string
+	store   HistoryStore
+	entries []Entry
+}
+
+// _baseHistory is the base to create an history.
+func _baseHistory(store HistoryStore, size int) *StoredHistory {
+	h := new(StoredHistory)
 	h.Cap = size
-	h.filename = fname
-	h.file = file
-	h.li = list.New()
+	h.session = strconv.Itoa(os.Getpid())
+	h.store = store
 
-	return h, nil
+	return h
 }
 
-// NewHistory creates a new history using the maximum length by default.
-func NewHistory(filename string) (*history, error) {
-	return _baseHistory(filename, HistoryCap)
+// NewHistory creates a new history saved to a text file, one line by entry,
+// using the maximum length by default.
+func NewHistory(filename string) (*StoredHistory, error) {
+	return NewHistoryOfSize(filename, HistoryCap)
 }
 
-// NewHistoryOfSize creates a new history whose buffer has the specified size,
-// which must be greater than zero.
-func NewHistoryOfSize(filename string, size int) (*history, error) {
+// NewHistoryOfSize creates a new history saved to a text file, one line by
+// entry, whose buffer has the specified size, which must be greater than zero.
+func NewHistoryOfSize(filename string, size int) (*StoredHistory, error) {
 	if size <= 0 {
 		return nil, errors.New("wrong history size: " + strconv.Itoa(size))
 	}
 
-	return _baseHistory(filename, size)
+	store, err := NewFileStore(filename, PlainFormat)
+	if err != nil {
+		return nil, err
+	}
+	return _baseHistory(store, size), nil
 }
 
-// == Access to file
+// NewHistoryOfStore creates a new history saved to the store, whose buffer has
+// the specified size, which must be greater than zero.
+func NewHistoryOfStore(store HistoryStore, size int) (*StoredHistory, error) {
+	if size <= 0 {
+		return nil, errors.New("wrong history size: " + strconv.Itoa(size))
+	}
 
-// Load loads the history from the file.
-func (h *history) Load() {
-	in := bufio.NewReader(h.file)
+	return _baseHistory(store, size), nil
+}
 
-	for {
-		line, err := in.ReadString('\n')
-		if err == io.EOF {
-			break
-		}
+// SetSession sets the identifier of the session, which is the process ID by
+// default.
+func (h *StoredHistory) SetSession(id string) {
+	h.session = id
+}
 
-		h.li.PushBack(strings.TrimRight(line, "\n"))
-	}
+// SetDupsPolicy sets how are handled the lines equal to others.
+func (h *StoredHistory) SetDupsPolicy(p DupsPolicy) {
+	h.dups = p
+}
 
-	h.mark = h.li.Back() // Point to an element.
+// == Access to store
+
+// Load loads the history from the store, keeping the last lines which fit in
+// its capacity.
+func (h *StoredHistory) Load() error {
+	entries, err := h.store.Load()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		h.push(e)
+	}
+	return nil
 }
 
-// Save saves all lines to the text file, excep when:
+// Save saves all lines to the store, whether it is a HistoryRewriter,
+// replacing its content, excep when:
 // + it starts with some space
 // + it is an empty line
-func (h *history) Save() (err error) {
-	if _, err = h.file.Seek(0, 0); err != nil {
-		return
+//
+// The lines saved to the store by other processes since it was loaded are
+// merged with the ones of the history.
+// The store is kept open, so the lines added after are saved too.
+func (h *StoredHistory) Save() error {
+	rw, ok := h.store.(HistoryRewriter)
+	if !ok {
+		return nil
 	}
+	return rw.Rewrite(h.savedEntries(), h.compact)
+}
 
-	out := bufio.NewWriter(h.file)
-	element := h.li.Front() // Get the first element.
-
-	for i := 0; i < h.li.Len(); i++ {
-		line := element.Value.(string)
+// Close closes the store, so the lines added after are not saved.
+func (h *StoredHistory) Close() error {
+	return h.store.Close()
+}
 
-		if strings.HasPrefix(line, " ") {
-			goto _next
-		}
-		if line = strings.TrimSpace(line); line == "" {
-			goto _next
-		}
-		if _, err = out.WriteString(line + "\n"); err != nil {
-			log.Println("history.Save:", err)
-			break
-		}
+// savedEntries returns the entries of the history which are saved to the
+// store, like they are written.
+func (h *StoredHistory) savedEntries() []Entry {
+	entries := make([]Entry, 0, len(h.entries))
 
-	_next:
-		if element = element.Next(); element == nil {
-			continue
+	for _, e := range h.entries {
+		if isSaved(e.Line) {
+			e.Line = strings.TrimSpace(e.Line)
+			entries = append(entries, e)
 		}
 	}
+	return entries
+}
 
-	if err = out.Flush(); err != nil {
-		log.Println("history.Save:", err)
+// compact returns the entries to keep, according to the capacity and the
+// policy about duplicates.
+func (h *StoredHistory) compact(entries []Entry) []Entry {
+	compacted := &StoredHistory{Cap: h.Cap, dups: h.dups}
+	for _, e := range entries {
+		compacted.push(e)
 	}
-
-	h.close()
-	return
+	return compacted.entries
 }
 
-// close Close the file descriptor.
-func (h *history) close() {
-	h.file.Close()
+// isSaved reports whether the line is saved to the store.
+func isSaved(line string) bool {
+	return !strings.HasPrefix(line, " ") && strings.TrimSpace(line) != ""
 }
 
-// openFile opens again the file.
-/*func (h *history) openFile() {
-	file, err := os.Open(fname, os.O_CREATE|os.O_RDWR, HistoryPerm)
-	if err != nil {
-		log.Println("history.openFile:", err)
-		return
+// Add adds a new line to the buffer, removing the oldest one when the capacity
+// is reached, and appends it to the store, according to the same rules that
+// Save.
+func (h *StoredHistory) Add(line string) error {
+	dir, _ := os.Getwd()
+	e := Entry{
+		Line:    line,
+		Time:    time.Unix(time.Now().Unix(), 0),
+		Dir:     dir,
+		Status:  -1,
+		Session: h.session,
+	}
+	if !h.push(e) || !isSaved(line) {
+		return nil
 	}
 
-	h.file = file
-}*/
+	e.Line = strings.TrimSpace(line)
+	return h.store.Append(e)
+}
 
-// Add adds a new line to the buffer.
-func (h *history) Add(line string) {
-	if h.li.Len() <= h.Cap {
-		h.mark = h.li.PushBack(line)
-	} else {
-		// TODO: overwrite lines
+// push adds an entry to the buffer, according to the policy about duplicates.
+// It reports whether the entry has been added.
+func (h *StoredHistory) push(e Entry) bool {
+	switch h.dups {
+	case IgnoreDups:
+		if n := len(h.entries); n != 0 && h.entries[n-1].Line == e.Line {
+			return false
+		}
+	case EraseDups:
+		kept := h.entries[:0]
+		for _, old := range h.entries {
+			if old.Line != e.Line {
+				kept = append(kept, old)
+			}
+		}
+		h.entries = kept
 	}
-}
 
-// _baseNextPrev is the base to move between lines.
-func (h *history) _baseNextPrev(c byte) (line []rune, err error) {
-	if h.li.Len() <= 0 {
-		return line, ErrEmptyHist
+	h.entries = append(h.entries, e)
+	if n := len(h.entries) - h.Cap; n > 0 {
+		h.entries = h.entries[:copy(h.entries, h.entries[n:])]
 	}
+	return true
+}
 
-	new := new(list.Element)
-	if c == 'p' {
-		new = h.mark.Prev()
-	} else if c == 'n' {
-		new = h.mark.Next()
-	} else {
-		panic("history._baseNextPrev: wrong character choice")
+// SetStatus sets the exit status of the last line added in the session, which
+// is saved whether the store supports it.
+func (h *StoredHistory) SetStatus(status int) error {
+	i := lastOfSession(h.entries, h.session)
+	if i == -1 {
+		return nil
 	}
+	h.entries[i].Status = status
 
-	if new != nil {
-		h.mark = new
-	} else {
-		return nil, ErrNilElement
+	if st, ok := h.store.(statusSaver); ok && isSaved(h.entries[i].Line) {
+		return st.saveStatus(h.session, status)
 	}
+	return nil
+}
+
+// Len returns the number of entries.
+func (h *StoredHistory) Len() int { return len(h.entries) }
+
+// At returns the entry at the position i, being 0 the oldest one.
+func (h *StoredHistory) At(i int) Entry { return h.entries[i] }
 
-	return []rune(new.Value.(string)), nil
+// == Query
+
+// HasPrefix returns the entries which start with prefix.
+func (h *StoredHistory) HasPrefix(prefix string) []Entry {
+	return h.filter(func(e Entry) bool { return strings.HasPrefix(e.Line, prefix) })
 }
 
-// Next returns the next line.
-func (h *history) Next() (line []rune, err error) {
-	return h._baseNextPrev('n')
+// Contains returns the entries which contain substr.
+func (h *StoredHistory) Contains(substr string) []Entry {
+	return h.filter(func(e Entry) bool { return strings.Contains(e.Line, substr) })
 }
 
-// Prev returns the previous line.
-func (h *history) Prev() (line []rune, err error) {
-	return h._baseNextPrev('p')
+// Between returns the entries added since the time from, until before of the
+// time to.
+func (h *StoredHistory) Between(from, to time.Time) []Entry {
+	return h.filter(func(e Entry) bool {
+		return !e.Time.Before(from) && e.Time.Before(to)
+	})
+}
+
+// Last returns the last n entries, or all whether there are less; none if n is
+// not positive.
+func (h *StoredHistory) Last(n int) []Entry {
+	if n <= 0 {
+		return nil
+	}
+	if n > len(h.entries) {
+		n = len(h.entries)
+	}
+	return append([]Entry(nil), h.entries[len(h.entries)-n:]...)
+}
+
+// filter returns the entries for which match is true.
+func (h *StoredHistory) filter(match func(Entry) bool) []Entry {
+	var entries []Entry
+	for _, e := range h.entries {
+		if match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
 }
 
 // == Utility
 
-// hasHistory checks whether has an history file.
-func hasHistory(h *history) bool {
+// hasHistory checks whether has an history.
+func hasHistory(h History) bool {
 	if h == nil {
 		return false
 	}
+	if h, ok := h.(*StoredHistory); ok && h == nil {
+		return false
+	}
 	return true
 }
@@ -0,0 +1,285 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A HistoryFormat represents the format of the history file. The files are
+// read in any format, but the lines are written in the one set. The entries
+// with several lines are saved like an entry by line, but in ZshFormat.
+type HistoryFormat int
+
+const (
+	PlainFormat HistoryFormat = iota // A line by entry; the format by default
+	BashFormat                       // A line "#<epoch>" before of each entry, like in Bash
+	ZshFormat                        // A line ": <epoch>:0;<entry>" by entry, like in zsh
+)
+
+// fileStore is a HistoryStore which saves the entries to a text file, which is
+// locked while it is accessed, so it can be shared by several processes.
+type fileStore struct {
+	file   *os.File
+	format HistoryFormat
+}
+
+// NewFileStore returns a store which saves the entries to the file name,
+// creating it whether it does not exist.
+// The formats BashFormat and ZshFormat save the time of every line, so the
+// lines added by several processes are merged in chronological order. The rest
+// of information of the entries is not saved.
+func NewFileStore(name string, format HistoryFormat) (HistoryRewriter, error) {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, HistoryPerm)
+	if err != nil {
+		return nil, err
+	}
+	return &fileStore{file: file, format: format}, nil
+}
+
+// Load returns the entries saved in the file.
+func (s *fileStore) Load() (entries []Entry, err error) {
+	if err = lockFile(s.file, false); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err2 := unlockFile(s.file); err == nil {
+			err = err2
+		}
+	}()
+
+	return readEntries(s.file)
+}
+
+// Append appends the entry to the file.
+func (s *fileStore) Append(e Entry) error {
+	if err := lockFile(s.file, true); err != nil {
+		return err
+	}
+	if err := s.writeEntry(s.file, e); err != nil {
+		unlockFile(s.file)
+		return err
+	}
+	return unlockFile(s.file)
+}
+
+// Rewrite replaces the content of the file by the entries, merged with the ones
+// added by other processes since the file was read.
+func (s *fileStore) Rewrite(entries []Entry, compact func([]Entry) []Entry) (err error) {
+	if err = lockFile(s.file, true); err != nil {
+		return err
+	}
+	defer func() {
+		if err2 := unlockFile(s.file); err == nil {
+			err = err2
+		}
+	}()
+
+	saved, err := readEntries(s.file)
+	if err != nil {
+		return err
+	}
+	entries = compact(mergeEntries(saved, s.split(entries)))
+
+	if err = s.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err = s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(s.file)
+	for _, e := range entries {
+		if err = s.writeEntry(out, e); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// split returns the entries like they are read from the file, whose format
+// could save every line of an entry like a different one.
+func (s *fileStore) split(entries []Entry) []Entry {
+	if s.format == ZshFormat {
+		return entries
+	}
+
+	split := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		for _, line := range strings.Split(e.Line, "\n") {
+			if line != "" {
+				e.Line = line
+				split = append(split, e)
+			}
+		}
+	}
+	return split
+}
+
+// Close closes the file.
+func (s *fileStore) Close() error {
+	return s.file.Close()
+}
+
+// readEntries reads the entries of the history file, in any format.
+func readEntries(file *os.File) ([]Entry, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	timed := false // If the last line was a timestamp in the format of Bash
+	in := bufio.NewScanner(file)
+
+	for in.Scan() {
+		line := in.Text()
+
+		if t, ok := parseBashTime(line); ok {
+			entries = append(entries, Entry{Time: t, Status: -1})
+			timed = true
+			continue
+		}
+		if t, text, ok := parseZshEntry(line); ok {
+			// The lines of an entry finish in a backslash, but the last one.
+			for strings.HasSuffix(text, "\\") && in.Scan() {
+				text = text[:len(text)-1] + "\n" + in.Text()
+			}
+			entries = append(entries, Entry{Line: text, Time: t, Status: -1})
+			timed = false
+			continue
+		}
+
+		if timed { // The line after of a timestamp is its entry.
+			entries[len(entries)-1].Line = line
+			timed = false
+			continue
+		}
+		if line != "" {
+			entries = append(entries, Entry{Line: line, Status: -1})
+		}
+	}
+	if err := in.Err(); err != nil {
+		return nil, err
+	}
+
+	// Remove the timestamps without a line.
+	valid := entries[:0]
+	for _, e := range entries {
+		if e.Line != "" {
+			valid = append(valid, e)
+		}
+	}
+	return valid, nil
+}
+
+// parseBashTime parses a line with a timestamp in the format of Bash, "#<epoch>".
+func parseBashTime(line string) (t time.Time, ok bool) {
+	if len(line) < 2 || line[0] != '#' {
+		return
+	}
+	return parseEpoch(line[1:])
+}
+
+// parseZshEntry parses a line in the format of the extended history of zsh,
+// ": <epoch>:<duration>;<entry>".
+func parseZshEntry(line string) (t time.Time, text string, ok bool) {
+	if !strings.HasPrefix(line, ": ") {
+		return
+	}
+	i := strings.IndexByte(line, ';')
+	if i == -1 {
+		return
+	}
+	fields := strings.SplitN(line[2:i], ":", 2)
+	if len(fields) != 2 {
+		return
+	}
+	if _, err := strconv.Atoi(fields[1]); err != nil {
+		return
+	}
+	if t, ok = parseEpoch(fields[0]); !ok {
+		return
+	}
+	return t, line[i+1:], true
+}
+
+// parseEpoch parses the number of seconds since the Unix epoch. The time 0 is
+// used for unknown times.
+func parseEpoch(s string) (t time.Time, ok bool) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || sec < 0 {
+		return
+	}
+	if sec == 0 {
+		return time.Time{}, true
+	}
+	return time.Unix(sec, 0), true
+}
+
+// writeEntry writes the entry e to w, in the format of the file.
+func (s *fileStore) writeEntry(w io.Writer, e Entry) (err error) {
+	line := e.Line
+	var sec int64
+	if !e.Time.IsZero() {
+		sec = e.Time.Unix()
+	}
+
+	switch s.format {
+	case BashFormat:
+		for _, line := range strings.Split(line, "\n") {
+			if _, err = fmt.Fprintf(w, "#%d\n%s\n", sec, line); err != nil {
+				return
+			}
+		}
+	case ZshFormat:
+		line = strings.Replace(line, "\n", "\\\n", -1)
+		_, err = fmt.Fprintf(w, ": %d:0;%s\n", sec, line)
+	default:
+		_, err = io.WriteString(w, line+"\n")
+	}
+	return
+}
+
+// mergeEntries merges the entries saved into a store with the ones of the
+// history, adding the ones which are not saved. The entries without time are
+// matched with any one with the same line. The result is sorted by time,
+// leaving the entries without time at the start.
+func mergeEntries(file, hist []Entry) []Entry {
+	merged := append([]Entry(nil), file...)
+
+	matched := make([]bool, len(file))
+	index := make(map[string][]int) // Positions of the lines in the file
+	for i, e := range file {
+		index[e.Line] = append(index[e.Line], i)
+	}
+
+	for _, e := range hist {
+		found := false
+		for _, i := range index[e.Line] {
+			if !matched[i] && (file[i].Time.Equal(e.Time) ||
+				file[i].Time.IsZero() || e.Time.IsZero()) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, e)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Time.Before(merged[j].Time)
+	})
+	return merged
+}
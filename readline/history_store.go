@@ -0,0 +1,181 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// A HistoryStore saves the entries of a history.
+type HistoryStore interface {
+	// Load returns the entries saved, from the oldest one.
+	Load() ([]Entry, error)
+
+	// Append saves a new entry.
+	Append(e Entry) error
+
+	// Close closes the store.
+	Close() error
+}
+
+// A HistoryRewriter is a HistoryStore whose entries can be replaced, so the
+// entries removed from the history are removed from the store too.
+type HistoryRewriter interface {
+	HistoryStore
+
+	// Rewrite replaces the entries saved by the given ones, merged with the
+	// ones saved by other histories since they were loaded. The function
+	// compact returns the entries to keep from the merged ones, according to
+	// the capacity of the history and its policy about duplicates.
+	Rewrite(entries []Entry, compact func([]Entry) []Entry) error
+}
+
+// statusSaver is a HistoryStore which saves the exit status of the last entry
+// appended in a session, once it is known.
+type statusSaver interface {
+	saveStatus(session string, status int) error
+}
+
+// lastOfSession returns the position of the last entry of the session, or -1.
+func lastOfSession(entries []Entry, session string) int {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Session == session {
+			return i
+		}
+	}
+	return -1
+}
+
+// == Memory
+
+// memoryStore is a HistoryRewriter which keeps the entries in memory.
+type memoryStore struct {
+	entries []Entry
+}
+
+// NewMemoryStore returns a store which keeps the entries in memory, so they
+// are lost at finishing the program. It is useful for tests and ephemeral
+// sessions.
+func NewMemoryStore() HistoryRewriter {
+	return new(memoryStore)
+}
+
+// Load returns the entries saved.
+func (s *memoryStore) Load() ([]Entry, error) {
+	return append([]Entry(nil), s.entries...), nil
+}
+
+// Append saves the entry.
+func (s *memoryStore) Append(e Entry) error {
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+// Rewrite replaces the entries saved.
+func (s *memoryStore) Rewrite(entries []Entry, compact func([]Entry) []Entry) error {
+	s.entries = compact(mergeEntries(s.entries, entries))
+	return nil
+}
+
+func (s *memoryStore) saveStatus(session string, status int) error {
+	if i := lastOfSession(s.entries, session); i != -1 {
+		s.entries[i].Status = status
+	}
+	return nil
+}
+
+// Close does nothing; the entries can be loaded after.
+func (s *memoryStore) Close() error { return nil }
+
+// == JSON Lines
+
+// jsonlStore is a HistoryStore which appends the entries to a file in format
+// JSON Lines, that is, an object JSON by line, with all their information.
+// The exit status is saved after like an entry without line, for the last one
+// of the session.
+type jsonlStore struct {
+	file *os.File
+}
+
+// NewJSONLStore returns a store which appends the entries to the file name,
+// creating it whether it does not exist, in format JSON Lines. The entries are
+// never removed from the file, which is locked while it is accessed, so it can
+// be shared by several processes.
+func NewJSONLStore(name string) (HistoryStore, error) {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, HistoryPerm)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlStore{file}, nil
+}
+
+// Load returns the entries saved in the file. The lines which are not a valid
+// entry, like the last one of a program killed while it was writing it, are
+// skipped.
+func (s *jsonlStore) Load() (entries []Entry, err error) {
+	if err = lockFile(s.file, false); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err2 := unlockFile(s.file); err == nil {
+			err = err2
+		}
+	}()
+
+	if _, err = s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	in := bufio.NewScanner(s.file)
+	in.Buffer(nil, BufferCap*16)
+
+	for in.Scan() {
+		var e Entry
+		if err = json.Unmarshal(in.Bytes(), &e); err != nil {
+			continue
+		}
+		if e.Line != "" {
+			entries = append(entries, e)
+		} else if i := lastOfSession(entries, e.Session); i != -1 {
+			entries[i].Status = e.Status
+		}
+	}
+	return entries, in.Err()
+}
+
+// Append appends the entry to the file.
+func (s *jsonlStore) Append(e Entry) error {
+	return s.write(e)
+}
+
+func (s *jsonlStore) saveStatus(session string, status int) error {
+	return s.write(Entry{Status: status, Session: session})
+}
+
+// write writes the entry like a line of the file.
+func (s *jsonlStore) write(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err = lockFile(s.file, true); err != nil {
+		return err
+	}
+	if _, err = s.file.Write(append(data, '\n')); err != nil {
+		unlockFile(s.file)
+		return err
+	}
+	return unlockFile(s.file)
+}
+
+// Close closes the file.
+func (s *jsonlStore) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,103 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// histLines returns the lines of the entries.
+func histLines(entries []Entry) []string {
+	var lines []string
+	for _, e := range entries {
+		lines = append(lines, e.Line)
+	}
+	return lines
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	// Two sessions sharing the store.
+	var hists [2]*StoredHistory
+	for i := range hists {
+		hist, err := NewHistoryOfStore(store, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hist.SetSession(string(rune('a' + i)))
+		hists[i] = hist
+	}
+
+	hists[0].Add("ls")
+	hists[1].Add("cd")
+	hists[1].SetStatus(2)
+	hists[0].Add(" secret")
+	hists[0].Add("make")
+	hists[0].Add("ls")
+	for _, hist := range hists {
+		if err := hist.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(histLines(entries), ","); got != "cd,make,ls" {
+		t.Errorf("expected %q, got %q", "cd,make,ls", got)
+	}
+	if entries[0].Status != 2 {
+		t.Errorf("expected status saved, got %d", entries[0].Status)
+	}
+}
+
+func TestJSONLStore(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := NewJSONLStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist, err := NewHistoryOfStore(store, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hist.Close()
+	hist.SetSession("test")
+
+	for _, s := range []string{"ls", "cd /", " secret", "echo 'a\nb'", "make"} {
+		hist.Add(s)
+	}
+	hist.SetStatus(1)
+	if err = hist.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entries are never removed from the file.
+	if store, err = NewJSONLStore(name); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	entries, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(histLines(entries), ","); got != "ls,cd /,echo 'a\nb',make" {
+		t.Errorf("wrong entries loaded: %q", got)
+	}
+
+	last := entries[len(entries)-1]
+	if last.Status != 1 || last.Session != "test" || last.Dir == "" || last.Time.IsZero() {
+		t.Errorf("wrong information of the last entry: %+v", last)
+	}
+	if entries[0].Status != -1 {
+		t.Errorf("expected unknown status, got %d", entries[0].Status)
+	}
+}
@@ -9,10 +9,13 @@
 package readline
 
 import (
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -26,7 +29,7 @@ func TestHistSave(t *testing.T) {
 		t.Error("could not create history", err)
 	}
 
-	if hist.li.Len() > hist.Cap {
+	if hist.Len() > hist.Cap {
 		t.Error("bad capacity size")
 	}
 
@@ -41,8 +44,9 @@ func TestHistSave(t *testing.T) {
 	hist.Add("9 line without trailing spaces")
 	hist.Add("10 line number 6")
 	hist.Save()
+	hist.Close()
 
-	historyLen = hist.li.Len() - 3 // 3 lines should not be saved
+	historyLen = hist.Len() - 3 // 3 lines should not be saved
 }
 
 func TestHistLoad(t *testing.T) {
@@ -52,10 +56,9 @@ func TestHistLoad(t *testing.T) {
 	}
 
 	hist.Load()
-	e := hist.li.Front()
 
-	for i := 0; i < hist.li.Len(); i++ {
-		line := e.Value.(string)
+	for i := 0; i < hist.Len(); i++ {
+		line := hist.At(i).Line
 
 		if strings.HasSuffix(line, "\n") || strings.HasSuffix(line, "\t") ||
 			strings.HasSuffix(line, " ") {
@@ -63,9 +66,239 @@ func TestHistLoad(t *testing.T) {
 		}
 	}
 
-	if hist.li.Len() != historyLen {
+	if hist.Len() != historyLen {
 		t.Error("length doesn't match with values saved")
 	}
 
 	os.Remove(historyFile)
 }
+
+func TestHistCap(t *testing.T) {
+	hist, err := NewHistoryOfSize(filepath.Join(t.TempDir(), "history"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hist.store.Close()
+
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		hist.Add(s)
+	}
+	if got := strings.Join(histLines(hist.entries), ","); got != "c,d,e" {
+		t.Errorf("expected the oldest lines removed, got %q", got)
+	}
+}
+
+func TestHistDups(t *testing.T) {
+	tests := []struct {
+		policy DupsPolicy
+		lines  string
+	}{
+		{KeepDups, "a,b,b,a,b"},
+		{IgnoreDups, "a,b,a,b"},
+		{EraseDups, "a,b"},
+	}
+	for _, tt := range tests {
+		hist, err := NewHistory(filepath.Join(t.TempDir(), "history"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hist.SetDupsPolicy(tt.policy)
+
+		for _, s := range []string{"a", "b", "b", "a", "b"} {
+			hist.Add(s)
+		}
+		if got := strings.Join(histLines(hist.entries), ","); got != tt.lines {
+			t.Errorf("policy %d: expected %q, got %q", tt.policy, tt.lines, got)
+		}
+		hist.Close()
+	}
+}
+
+func TestHistAppend(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "history")
+	hist, err := NewHistory(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.SetDupsPolicy(EraseDups)
+
+	for _, s := range []string{"a long line", "b", " c", "b"} {
+		hist.Add(s)
+	}
+	// The lines are appended at adding them.
+	if data, _ := ioutil.ReadFile(name); string(data) != "a long line\nb\nb\n" {
+		t.Errorf("expected lines appended, got %q", data)
+	}
+
+	// The file is truncated at saving.
+	if err = hist.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(name); string(data) != "a long line\nb\n" {
+		t.Errorf("expected file replaced, got %q", data)
+	}
+
+	// The lines added after of saving are appended too.
+	if err = hist.Add("d"); err != nil {
+		t.Fatal(err)
+	}
+	if err = hist.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(name); string(data) != "a long line\nb\nd\n" {
+		t.Errorf("expected line appended after saving, got %q", data)
+	}
+
+	if err = hist.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = hist.Add("e"); err == nil {
+		t.Error("expected error adding to a closed history")
+	}
+}
+
+func TestHistQuery(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hist.store.Close()
+
+	hist.SetSession("test")
+	for _, s := range []string{"ls -l", "echo ls", "ls", "cd"} {
+		hist.Add(s)
+	}
+	hist.SetStatus(1)
+	hist.entries[0].Time = time.Unix(100, 0)
+
+	if e := hist.At(hist.Len() - 1); e.Status != 1 || e.Session != "test" || e.Dir == "" {
+		t.Errorf("wrong metadata of the last entry: %+v", e)
+	}
+	if e := hist.At(0); e.Status != -1 {
+		t.Errorf("expected unknown status, got %d", e.Status)
+	}
+
+	tests := []struct {
+		name    string
+		entries []Entry
+		lines   string
+	}{
+		{"HasPrefix", hist.HasPrefix("ls"), "ls -l,ls"},
+		{"Contains", hist.Contains("ls"), "ls -l,echo ls,ls"},
+		{"Between", hist.Between(time.Unix(0, 0), time.Unix(200, 0)), "ls -l"},
+		{"Last", hist.Last(2), "ls,cd"},
+		{"Last", hist.Last(10), "ls -l,echo ls,ls,cd"},
+		{"Last", hist.Last(0), ""},
+		{"Last", hist.Last(-1), ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(histLines(tt.entries), ","); got != tt.lines {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.lines, got)
+		}
+	}
+}
+
+func TestHistMerge(t *testing.T) {
+	for _, format := range []HistoryFormat{PlainFormat, BashFormat, ZshFormat} {
+		name := filepath.Join(t.TempDir(), "history")
+		if err := ioutil.WriteFile(name, []byte("old\n"), HistoryPerm); err != nil {
+			t.Fatal(err)
+		}
+
+		// Two processes sharing the file.
+		var hists [2]*StoredHistory
+		for i := range hists {
+			store, err := NewFileStore(name, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hist, _ := NewHistoryOfStore(store, HistoryCap)
+			if err = hist.Load(); err != nil {
+				t.Fatal(err)
+			}
+			hists[i] = hist
+		}
+
+		hists[0].Add("a")
+		hists[1].Add("b")
+		hists[0].Add("c\nd")
+		for _, hist := range hists {
+			if err := hist.Save(); err != nil {
+				t.Fatal(err)
+			}
+			hist.Close()
+		}
+
+		hist, err := NewHistory(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = hist.Load(); err != nil {
+			t.Fatal(err)
+		}
+		hist.store.Close()
+
+		want := "old,a,b,c,d"
+		if format == ZshFormat {
+			want = "old,a,b,c\nd"
+		}
+		if got := strings.Join(histLines(hist.entries), ","); got != want {
+			t.Errorf("format %d: expected %q, got %q", format, want, got)
+		}
+	}
+}
+
+func TestReadEntries(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "history")
+	data := "plain\n" +
+		"#100\nbash\n#0\nno time\n#200\ntimed\nplain\n\n#300\n" +
+		": 300:0;zsh\n: 400:5;multi\\\nline\n"
+	if err := ioutil.WriteFile(name, []byte(data), HistoryPerm); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	got, err := readEntries(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Line: "plain"},
+		{Line: "bash", Time: time.Unix(100, 0)},
+		{Line: "no time"},
+		{Line: "timed", Time: time.Unix(200, 0)},
+		{Line: "plain"},
+		{Line: "zsh", Time: time.Unix(300, 0)},
+		{Line: "multi\nline", Time: time.Unix(400, 0)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i].Line != want[i].Line || !got[i].Time.Equal(want[i].Time) {
+			t.Errorf("entry %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestMergeEntries(t *testing.T) {
+	file := []Entry{
+		{Line: "a", Time: time.Unix(1, 0)},
+		{Line: "c", Time: time.Unix(3, 0)},
+		{Line: "x"},
+	}
+	hist := []Entry{
+		{Line: "x", Time: time.Unix(2, 0)},
+		{Line: "b", Time: time.Unix(2, 0)},
+		{Line: "c", Time: time.Unix(3, 0)},
+		{Line: "d", Time: time.Unix(4, 0)},
+	}
+
+	if got := strings.Join(histLines(mergeEntries(file, hist)), ","); got != "x,a,b,c,d" {
+		t.Errorf("expected %q, got %q", "x,a,b,c,d", got)
+	}
+}
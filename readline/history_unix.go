@@ -0,0 +1,42 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// +build !plan9,!windows
+
+package readline
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile sets an advisory lock on the file, waiting until it is got. The
+// lock is exclusive for writing, else it is shared.
+func lockFile(f *os.File, write bool) error {
+	how := unix.LOCK_SH
+	if write {
+		how = unix.LOCK_EX
+	}
+
+	for {
+		err := unix.Flock(int(f.Fd()), how)
+		if err != unix.EINTR {
+			if err != nil {
+				return os.NewSyscallError("flock", err)
+			}
+			return nil
+		}
+	}
+}
+
+// unlockFile removes the advisory lock on the file.
+func unlockFile(f *os.File) error {
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_UN); err != nil {
+		return os.NewSyscallError("flock", err)
+	}
+	return nil
+}
@@ -0,0 +1,504 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tredoe/term/keys"
+)
+
+// An initFileError represents an error in a line of an init file.
+type initFileError struct {
+	file string
+	line int
+	msg  string
+}
+
+func (e initFileError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.file, e.line, e.msg)
+}
+
+// InitFile returns the name of the init file by default, which is got from the
+// environment variable INPUTRC, or else it is "~/.inputrc".
+func InitFile() string {
+	if name := os.Getenv("INPUTRC"); name != "" {
+		return name
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".inputrc")
+}
+
+// SetAppName sets the name of the application, to be checked in the
+// conditional constructs of the init file.
+func (ln *Line) SetAppName(name string) {
+	ln.appName = name
+}
+
+// ReadInitFile reads the init file with the given name, like "~/.inputrc" in
+// GNU Readline, to configure the line. If name is empty, it is used the one
+// by default, which is not required to exist.
+//
+// It is supported a subset of the syntax of GNU Readline:
+//
+//	set editing-mode vi|emacs
+//	set keymap emacs|vi|vi-command|vi-insert
+//	set bell-style none|visible|audible
+//	set completion-ignore-case on|off
+//
+//	"\C-x\C-r": re-read-init-file
+//	Meta-Rubout: backward-kill-word
+//
+//	$if mode=emacs|vi, $if term=name, $if application-name, $else, $endif
+//	$include file
+//
+// The rest of variables are ignored. The lines with errors are skipped, and
+// the first error found is returned once the file is read.
+func (ln *Line) ReadInitFile(name string) error {
+	if name == "" {
+		name = InitFile()
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	ln.initFile = name
+
+	p := &initParser{ln: ln, keymap: ln.mode}
+	p.readFile(name, 0)
+	return p.err
+}
+
+// rereadInitFile reads again the last init file read.
+func rereadInitFile(ln *Line, _ keys.Key) error {
+	if err := ln.ReadInitFile(ln.initFile); err != nil {
+		return ln.bell()
+	}
+	return nil
+}
+
+// maxIncludes is the maximum depth of files included.
+const maxIncludes = 10
+
+// initParser represents the state of the parsing of init files.
+type initParser struct {
+	ln     *Line
+	keymap Mode // Keymap where the keys are bound
+	conds  []bool
+
+	file   string
+	lineNo int
+	err    error // First error found
+}
+
+// errorf records the error of the actual line, if it is the first one.
+func (p *initParser) errorf(format string, a ...interface{}) {
+	if p.err == nil {
+		p.err = initFileError{p.file, p.lineNo, fmt.Sprintf(format, a...)}
+	}
+}
+
+// readFile reads the init file with the given name, included at the depth.
+func (p *initParser) readFile(name string, depth int) {
+	if strings.HasPrefix(name, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			name = filepath.Join(home, name[2:])
+		}
+	}
+	if depth > maxIncludes {
+		p.errorf("too many files included: %s", name)
+		return
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if p.err == nil {
+			p.err = err
+		}
+		return
+	}
+	defer f.Close()
+
+	file, lineNo := p.file, p.lineNo
+	defer func() { p.file, p.lineNo = file, lineNo }()
+	p.file, p.lineNo = name, 0
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		p.lineNo++
+		p.parseLine(strings.TrimSpace(s.Text()), depth)
+	}
+	if err = s.Err(); err != nil && p.err == nil {
+		p.err = err
+	}
+}
+
+// active reports whether the lines are not skipped by a conditional construct.
+func (p *initParser) active() bool {
+	for _, c := range p.conds {
+		if !c {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLine parses a line of the file, without leading and trailing spaces.
+func (p *initParser) parseLine(line string, depth int) {
+	if line == "" || line[0] == '#' {
+		return
+	}
+
+	if line[0] == '$' {
+		directive, arg := splitWord(line[1:])
+		switch directive {
+		case "if":
+			p.conds = append(p.conds, p.eval(arg))
+		case "else":
+			if len(p.conds) == 0 {
+				p.errorf("$else without $if")
+				return
+			}
+			p.conds[len(p.conds)-1] = !p.conds[len(p.conds)-1]
+		case "endif":
+			if len(p.conds) == 0 {
+				p.errorf("$endif without $if")
+				return
+			}
+			p.conds = p.conds[:len(p.conds)-1]
+		case "include":
+			if !p.active() {
+				return
+			}
+			if !filepath.IsAbs(arg) && !strings.HasPrefix(arg, "~/") {
+				arg = filepath.Join(filepath.Dir(p.file), arg)
+			}
+			p.readFile(arg, depth+1)
+		default:
+			p.errorf("unknown directive: $%s", directive)
+		}
+		return
+	}
+
+	if !p.active() {
+		return
+	}
+	if word, arg := splitWord(line); word == "set" {
+		name, value := splitWord(arg)
+		p.set(strings.ToLower(name), value)
+		return
+	}
+	p.bind(line)
+}
+
+// eval evaluates the test of a conditional construct.
+func (p *initParser) eval(test string) bool {
+	switch {
+	case strings.HasPrefix(test, "mode="):
+		if strings.TrimPrefix(test, "mode=") == "vi" {
+			return p.ln.mode != EmacsMode
+		}
+		return p.ln.mode == EmacsMode
+
+	case strings.HasPrefix(test, "term="):
+		name := strings.TrimPrefix(test, "term=")
+		term := os.Getenv("TERM")
+		if i := strings.IndexByte(term, '-'); i != -1 && term[:i] == name {
+			return true
+		}
+		return term == name
+	}
+	return p.ln.appName != "" && strings.EqualFold(test, p.ln.appName)
+}
+
+// set sets the variable with the given name.
+func (p *initParser) set(name, value string) {
+	value = strings.ToLower(value)
+
+	switch name {
+	case "editing-mode":
+		switch value {
+		case "emacs":
+			p.keymap = EmacsMode
+		case "vi":
+			p.keymap = ViInsertMode
+		default:
+			p.errorf("invalid editing mode: %s", value)
+			return
+		}
+		if err := p.ln.SetMode(p.keymap); err != nil {
+			p.errorf("%s", err)
+		}
+
+	case "keymap":
+		switch value {
+		case "emacs", "emacs-standard":
+			p.keymap = EmacsMode
+		case "vi", "vi-command", "vi-move":
+			p.keymap = ViCommandMode
+		case "vi-insert":
+			p.keymap = ViInsertMode
+		default:
+			p.errorf("invalid keymap: %s", value)
+		}
+
+	case "bell-style":
+		switch value {
+		case "none", "off":
+			p.ln.SetBellStyle(BellNone)
+		case "visible":
+			p.ln.SetBellStyle(BellVisible)
+		case "audible", "on":
+			p.ln.SetBellStyle(BellAudible)
+		default:
+			p.errorf("invalid bell style: %s", value)
+		}
+
+	case "completion-ignore-case":
+		p.ln.SetCompletionIgnoreCase(value == "on" || value == "1")
+	}
+}
+
+// bind parses a key binding.
+func (p *initParser) bind(line string) {
+	var seq []byte
+	var rest string
+	var err error
+
+	if line[0] == '"' {
+		end := 1
+		for ; end < len(line) && line[end] != '"'; end++ {
+			if line[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(line) {
+			p.errorf("unterminated key sequence")
+			return
+		}
+		if seq, err = parseKeySeq(line[1:end]); err != nil {
+			p.errorf("%s", err)
+			return
+		}
+		rest = strings.TrimSpace(line[end+1:])
+		if !strings.HasPrefix(rest, ":") {
+			p.errorf("missing colon after of the key sequence")
+			return
+		}
+		rest = rest[1:]
+	} else {
+		i := strings.IndexByte(line, ':')
+		if i == -1 {
+			p.errorf("missing colon after of the key name")
+			return
+		}
+		if seq, err = parseKeyName(line[:i]); err != nil {
+			p.errorf("%s", err)
+			return
+		}
+		rest = line[i+1:]
+	}
+
+	command, _ := splitWord(strings.TrimSpace(rest))
+	switch {
+	case command == "":
+		p.errorf("missing command")
+		return
+	case command[0] == '"' || command[0] == '\'':
+		p.errorf("macros are not supported")
+		return
+	}
+
+	if err = p.ln.keymaps[p.keymap].Bind(command, decodeKeys(seq)...); err != nil {
+		p.errorf("%s", err)
+	}
+}
+
+// == Key sequences
+//
+
+// splitWord returns the first word of s, and the rest without leading spaces.
+func splitWord(s string) (word, rest string) {
+	if i := strings.IndexAny(s, " \t"); i != -1 {
+		return s[:i], strings.TrimSpace(s[i+1:])
+	}
+	return s, ""
+}
+
+// decodeKeys returns the keys of the bytes sent by the terminal.
+func decodeKeys(p []byte) []keys.Key {
+	var d keys.Decoder
+	var seq []keys.Key
+
+	d.Feed(p)
+	for {
+		k, ok := d.Next()
+		if !ok {
+			if k, ok = d.Flush(); !ok {
+				return seq
+			}
+		}
+		seq = append(seq, k)
+	}
+}
+
+// control returns the control character of c.
+func control(c byte) byte {
+	if c == '?' {
+		return 0x7F
+	}
+	return c & 0x1F
+}
+
+// parseKeySeq parses a key sequence like "\C-x\C-r", without quotes.
+func parseKeySeq(s string) ([]byte, error) {
+	var seq []byte
+	for s != "" {
+		b, n, err := parseKeyChar(s)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, b...)
+		s = s[n:]
+	}
+	if len(seq) == 0 {
+		return nil, ErrEmptySeq
+	}
+	return seq, nil
+}
+
+// parseKeyChar parses the first character of a key sequence, returning its
+// bytes and the number of bytes parsed.
+func parseKeyChar(s string) (b []byte, n int, err error) {
+	switch {
+	case strings.HasPrefix(s, `\C-`):
+		if b, n, err = parseKeyChar(s[3:]); err != nil {
+			return nil, 0, err
+		}
+		b[len(b)-1] = control(b[len(b)-1])
+		return b, n + 3, nil
+
+	case strings.HasPrefix(s, `\M-`):
+		if b, n, err = parseKeyChar(s[3:]); err != nil {
+			return nil, 0, err
+		}
+		return append([]byte{0x1B}, b...), n + 3, nil
+
+	case s == "":
+		return nil, 0, fmt.Errorf("key sequence cut off")
+
+	case s[0] != '\\':
+		_, n = utf8.DecodeRuneInString(s)
+		return []byte(s[:n]), n, nil
+	}
+
+	if len(s) < 2 {
+		return nil, 0, fmt.Errorf("key sequence cut off")
+	}
+	switch c := s[1]; c {
+	case 'e':
+		return []byte{0x1B}, 2, nil
+	case 'a':
+		return []byte{'\a'}, 2, nil
+	case 'b':
+		return []byte{'\b'}, 2, nil
+	case 'd':
+		return []byte{0x7F}, 2, nil
+	case 'f':
+		return []byte{'\f'}, 2, nil
+	case 'n':
+		return []byte{'\n'}, 2, nil
+	case 'r':
+		return []byte{'\r'}, 2, nil
+	case 't':
+		return []byte{'\t'}, 2, nil
+	case 'v':
+		return []byte{'\v'}, 2, nil
+
+	case 'x':
+		for n = 2; n < len(s) && n < 4 && strings.IndexByte("0123456789abcdefABCDEF", s[n]) != -1; n++ {
+		}
+		v, err := strconv.ParseUint(s[2:n], 16, 8)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid hexadecimal escape: %s", s[:n])
+		}
+		return []byte{byte(v)}, n, nil
+
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		for n = 1; n < len(s) && n < 4 && '0' <= s[n] && s[n] <= '7'; n++ {
+		}
+		v, err := strconv.ParseUint(s[1:n], 8, 8)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid octal escape: %s", s[:n])
+		}
+		return []byte{byte(v)}, n, nil
+	}
+	// Like \\, \" and \'
+	return []byte{s[1]}, 2, nil
+}
+
+// keyNames are the names of the keys, in lower case, which can be used in the
+// bindings.
+var keyNames = map[string]byte{
+	"del":     0x7F,
+	"rubout":  0x7F,
+	"esc":     0x1B,
+	"escape":  0x1B,
+	"lfd":     '\n',
+	"newline": '\n',
+	"ret":     '\r',
+	"return":  '\r',
+	"spc":     ' ',
+	"space":   ' ',
+	"tab":     '\t',
+}
+
+// parseKeyName parses a key name like "Control-u" or "Meta-Rubout".
+func parseKeyName(s string) ([]byte, error) {
+	var meta, ctrl bool
+
+	for {
+		i := strings.IndexByte(s, '-')
+		if i == -1 || i == len(s)-1 {
+			break
+		}
+		switch strings.ToLower(s[:i]) {
+		case "c", "control":
+			ctrl = true
+		case "m", "meta":
+			meta = true
+		default:
+			return nil, fmt.Errorf("invalid key name: %s", s)
+		}
+		s = s[i+1:]
+	}
+
+	var b byte
+	if c, ok := keyNames[strings.ToLower(s)]; ok {
+		b = c
+	} else if len(s) == 1 {
+		b = s[0]
+	} else {
+		return nil, fmt.Errorf("invalid key name: %s", s)
+	}
+
+	if ctrl {
+		b = control(b)
+	}
+	if meta {
+		return []byte{0x1B, b}, nil
+	}
+	return []byte{b}, nil
+}
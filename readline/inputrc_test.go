@@ -0,0 +1,186 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tredoe/term/keys"
+)
+
+func TestParseKeySeq(t *testing.T) {
+	tests := []struct {
+		in  string
+		seq []keys.Key
+	}{
+		{`\C-x\C-r`, []keys.Key{ctrl('x'), ctrl('r')}},
+		{`\M-d`, []keys.Key{alt('d')}},
+		{`\e[A`, []keys.Key{{Code: keys.Up}}},
+		{`\C-?`, []keys.Key{{Code: keys.Backspace}}},
+		{`\M-\C-?`, []keys.Key{{Code: keys.Backspace, Mods: keys.ModAlt}}},
+		{`\t`, []keys.Key{{Code: keys.Tab}}},
+		{`\x41\102\\`, []keys.Key{
+			{Code: keys.Rune, Rune: 'A'},
+			{Code: keys.Rune, Rune: 'B'},
+			{Code: keys.Rune, Rune: '\\'},
+		}},
+		{`ñ`, []keys.Key{{Code: keys.Rune, Rune: 'ñ'}}},
+	}
+	for _, tt := range tests {
+		seq, err := parseKeySeq(tt.in)
+		if err != nil {
+			t.Errorf("%q: %s", tt.in, err)
+			continue
+		}
+		if got := decodeKeys(seq); !reflect.DeepEqual(got, tt.seq) {
+			t.Errorf("%q: expected %v, got %v", tt.in, tt.seq, got)
+		}
+	}
+
+	for _, in := range []string{``, `\C-`, `\`, `\xZ`} {
+		if _, err := parseKeySeq(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestParseKeyName(t *testing.T) {
+	tests := []struct {
+		in  string
+		key keys.Key
+	}{
+		{"Control-u", ctrl('u')},
+		{"C-u", ctrl('u')},
+		{"Meta-d", alt('d')},
+		{"Meta-Rubout", keys.Key{Code: keys.Backspace, Mods: keys.ModAlt}},
+		{"RET", keys.Key{Code: keys.Enter}},
+		{"x", keys.Key{Code: keys.Rune, Rune: 'x'}},
+	}
+	for _, tt := range tests {
+		seq, err := parseKeyName(tt.in)
+		if err != nil {
+			t.Errorf("%q: %s", tt.in, err)
+			continue
+		}
+		if got := decodeKeys(seq); len(got) != 1 || got[0] != tt.key {
+			t.Errorf("%q: expected %v, got %v", tt.in, tt.key, got)
+		}
+	}
+
+	for _, in := range []string{"Hyper-x", "Control-", "foo"} {
+		if _, err := parseKeyName(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestReadInitFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) string {
+		name = filepath.Join(dir, name)
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+
+	writeFile("included", `"\C-xa": yank`)
+	name := writeFile("inputrc", `
+# Comment
+set bell-style none
+set completion-ignore-case On
+set some-variable on
+
+"\C-x\C-y": yank
+Meta-Rubout: unix-word-rubout
+$include included
+
+$if term=xterm
+"\C-xt": undo
+$else
+"\C-xt": redo
+$endif
+
+$if Test
+"\C-xn": kill-word
+$endif
+$if Other
+"\C-xo": kill-word
+$endif
+
+set editing-mode vi
+$if mode=vi
+set keymap vi-command
+"\C-xv": undo
+$endif
+`)
+
+	os.Setenv("TERM", "xterm-256color")
+	ln := &Line{keymaps: newKeymaps()}
+	ln.SetAppName("test")
+
+	if err := ln.ReadInitFile(name); err != nil {
+		t.Fatal(err)
+	}
+	if ln.bellStyle != BellNone || !ln.ignoreCase || ln.mode != ViInsertMode {
+		t.Errorf("variables not set: bell style %v, ignore case %v, mode %v",
+			ln.bellStyle, ln.ignoreCase, ln.mode)
+	}
+
+	tests := []struct {
+		mode    Mode
+		seq     []keys.Key
+		command string
+	}{
+		{EmacsMode, []keys.Key{ctrl('x'), ctrl('y')}, "yank"},
+		{EmacsMode, []keys.Key{{Code: keys.Backspace, Mods: keys.ModAlt}}, "unix-word-rubout"},
+		{EmacsMode, []keys.Key{ctrl('x'), {Code: keys.Rune, Rune: 'a'}}, "yank"},
+		{EmacsMode, []keys.Key{ctrl('x'), {Code: keys.Rune, Rune: 't'}}, "undo"},
+		{EmacsMode, []keys.Key{ctrl('x'), {Code: keys.Rune, Rune: 'n'}}, "kill-word"},
+		{EmacsMode, []keys.Key{ctrl('x'), {Code: keys.Rune, Rune: 'o'}}, ""},
+		{ViCommandMode, []keys.Key{ctrl('x'), {Code: keys.Rune, Rune: 'v'}}, "undo"},
+		{EmacsMode, []keys.Key{ctrl('x'), {Code: keys.Rune, Rune: 'v'}}, ""},
+	}
+	for _, tt := range tests {
+		if command, _ := ln.keymaps[tt.mode].Lookup(tt.seq...); command != tt.command {
+			t.Errorf("%v: expected %q, got %q", tt.seq, tt.command, command)
+		}
+	}
+
+	// Errors
+	name = writeFile("errors", `
+set editing-mode foo
+"\C-xa" yank
+"\C-xb": no-command
+$endif
+"\C-xc": kill-word
+`)
+	ln = &Line{keymaps: newKeymaps()}
+	err := ln.ReadInitFile(name)
+	if err == nil || !strings.HasPrefix(err.Error(), name+":2: ") {
+		t.Errorf("expected error at line 2, got %v", err)
+	}
+	if command, _ := ln.Keymap().Lookup(ctrl('x'), keys.Key{Code: keys.Rune, Rune: 'c'}); command != "kill-word" {
+		t.Error("the lines after of an error should be read")
+	}
+
+	// The prompt of the mode can not be written.
+	name = writeFile("mode", "\nset editing-mode vi\n")
+	_, out := io.Pipe()
+	out.Close()
+	ln = &Line{keymaps: newKeymaps(), buf: newBuffer(out, 80), reading: true}
+	ln.SetModePrompt(func(Mode) string { return "> " })
+	err = ln.ReadInitFile(name)
+	if err == nil || !strings.HasPrefix(err.Error(), name+":2: ") {
+		t.Errorf("expected error at line 2, got %v", err)
+	}
+}
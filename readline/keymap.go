@@ -0,0 +1,353 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/tredoe/term/keys"
+)
+
+// An unknownCommandError represents a command not registered.
+type unknownCommandError string
+
+func (e unknownCommandError) Error() string {
+	return "keymap: unknown command: " + string(e)
+}
+
+// A Command is run at pressing the key sequence bound to it, being key the
+// last key pressed. The error returned, if any, is returned by Line.Read.
+type Command func(ln *Line, key keys.Key) error
+
+var (
+	commandsMu sync.RWMutex
+	commands   = make(map[string]Command) // Commands by name
+)
+
+// RegisterCommand registers the command cmd with the given name, replacing
+// the command registered with the same name, if any, so it can be bound to a
+// key sequence.
+func RegisterCommand(name string, cmd Command) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+
+	if cmd == nil {
+		delete(commands, name)
+		return
+	}
+	commands[name] = cmd
+}
+
+// lookupCommand returns the command registered with the given name.
+func lookupCommand(name string) Command {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	return commands[name]
+}
+
+// Commands returns the names of the commands registered, sorted.
+func Commands() []string {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// == Keymap
+//
+
+// A Keymap maps key sequences to the names of the commands to run.
+//
+// The characters which are not bound run the command "self-insert", unless
+// the keymap is for the vi command mode.
+// A key pressed together with Alt which is not bound is handled like Escape
+// followed by that key, whether Escape is bound to a command.
+type Keymap struct {
+	keys   map[keys.Key]*binding
+	insert bool // If the characters not bound are inserted
+}
+
+// A binding represents the command bound to a key, or the keymap of the keys
+// which can follow it.
+type binding struct {
+	command string
+	prefix  *Keymap
+}
+
+// NewKeymap returns a keymap without key bindings.
+func NewKeymap() *Keymap {
+	return &Keymap{keys: make(map[keys.Key]*binding), insert: true}
+}
+
+// Bind binds the key sequence seq to the command with the given name.
+// A key sequence which is a prefix of another one can not be bound, so any
+// binding to a prefix of seq, or with seq as prefix, is replaced.
+func (km *Keymap) Bind(command string, seq ...keys.Key) error {
+	if len(seq) == 0 {
+		return ErrEmptySeq
+	}
+	if lookupCommand(command) == nil {
+		return unknownCommandError(command)
+	}
+
+	for _, k := range seq[:len(seq)-1] {
+		b := km.keys[k]
+		if b == nil || b.prefix == nil {
+			b = &binding{prefix: NewKeymap()}
+			km.keys[k] = b
+		}
+		km = b.prefix
+	}
+	km.keys[seq[len(seq)-1]] = &binding{command: command}
+	return nil
+}
+
+// Unbind removes the binding of the key sequence seq, if any.
+func (km *Keymap) Unbind(seq ...keys.Key) {
+	if len(seq) == 0 {
+		return
+	}
+
+	for _, k := range seq[:len(seq)-1] {
+		b := km.keys[k]
+		if b == nil || b.prefix == nil {
+			return
+		}
+		km = b.prefix
+	}
+	delete(km.keys, seq[len(seq)-1])
+}
+
+// Lookup returns the name of the command bound to the key sequence seq.
+// It reports false if the sequence is not bound, or if it is a prefix.
+func (km *Keymap) Lookup(seq ...keys.Key) (command string, ok bool) {
+	for i, k := range seq {
+		b := km.keys[k]
+		if b == nil {
+			break
+		}
+		if i == len(seq)-1 {
+			return b.command, b.prefix == nil
+		}
+		if b.prefix == nil {
+			break
+		}
+		km = b.prefix
+	}
+	return "", false
+}
+
+// Clone returns a copy of the keymap.
+func (km *Keymap) Clone() *Keymap {
+	c := NewKeymap()
+	c.insert = km.insert
+	for k, b := range km.keys {
+		if b.prefix != nil {
+			c.keys[k] = &binding{prefix: b.prefix.Clone()}
+		} else {
+			c.keys[k] = &binding{command: b.command}
+		}
+	}
+	return c
+}
+
+// readCommand reads keys until of getting a sequence bound in the keymap,
+// returning the command and the last key pressed. The command is nil whether
+// the sequence is not bound.
+func (km *Keymap) readCommand(ln *Line) (cmd Command, key keys.Key, err error) {
+	for prefix := false; ; prefix = true {
+		if key, err = ln.readKey(); err != nil {
+			return nil, key, err
+		}
+
+		b := km.keys[key]
+		switch {
+		case b == nil:
+			if prefix {
+				return nil, key, nil
+			}
+			if key.IsRune() && km.insert {
+				return lookupCommand("self-insert"), key, nil
+			}
+			if key.Mods&keys.ModAlt != 0 {
+				esc := keys.Key{Code: keys.Escape}
+				if b = km.keys[esc]; b != nil && b.prefix == nil {
+					key.Mods &^= keys.ModAlt
+					ln.in.UnreadKey(key)
+					return lookupCommand(b.command), esc, nil
+				}
+			}
+			return nil, key, nil
+		case b.prefix != nil:
+			km = b.prefix
+		default:
+			return lookupCommand(b.command), key, nil
+		}
+	}
+}
+
+// == Default keymap
+//
+
+// A keyBinding represents a key sequence bound to a command.
+type keyBinding struct {
+	command string
+	seq     []keys.Key
+}
+
+// emacsBindings are the key bindings by default, like in GNU Readline.
+var emacsBindings = []keyBinding{
+	{"accept-line", []keys.Key{{Code: keys.Enter}}},
+	{"accept-line", []keys.Key{ctrl('j')}},
+	{"complete", []keys.Key{{Code: keys.Tab}}},
+	{"interrupt", []keys.Key{ctrl('c')}},
+	{"end-of-file", []keys.Key{ctrl('d')}},
+	{"abort", []keys.Key{ctrl('g')}},
+	{"clear-screen", []keys.Key{ctrl('l')}},
+	{"re-read-init-file", []keys.Key{ctrl('x'), ctrl('r')}},
+
+	{"backward-char", []keys.Key{{Code: keys.Left}}},
+	{"backward-char", []keys.Key{ctrl('b')}},
+	{"forward-char", []keys.Key{{Code: keys.Right}}},
+	{"forward-char", []keys.Key{ctrl('f')}},
+	{"backward-word", []keys.Key{{Code: keys.Left, Mods: keys.ModCtrl}}},
+	{"backward-word", []keys.Key{alt('b')}},
+	{"forward-word", []keys.Key{{Code: keys.Right, Mods: keys.ModCtrl}}},
+	{"forward-word", []keys.Key{alt('f')}},
+	{"beginning-of-line", []keys.Key{{Code: keys.Home}}},
+	{"beginning-of-line", []keys.Key{ctrl('a')}},
+	{"end-of-line", []keys.Key{{Code: keys.End}}},
+	{"end-of-line", []keys.Key{ctrl('e')}},
+
+	{"previous-history", []keys.Key{{Code: keys.Up}}},
+	{"previous-history", []keys.Key{ctrl('p')}},
+	{"next-history", []keys.Key{{Code: keys.Down}}},
+	{"next-history", []keys.Key{ctrl('n')}},
+	{"reverse-search-history", []keys.Key{ctrl('r')}},
+	{"forward-search-history", []keys.Key{ctrl('s')}},
+
+	{"backward-delete-char", []keys.Key{{Code: keys.Backspace}}},
+	{"backward-delete-char", []keys.Key{ctrl('h')}},
+	{"delete-char", []keys.Key{{Code: keys.Delete}}},
+	{"transpose-chars", []keys.Key{ctrl('t')}},
+
+	{"kill-line", []keys.Key{ctrl('k')}},
+	{"kill-whole-line", []keys.Key{ctrl('u')}},
+	{"unix-word-rubout", []keys.Key{ctrl('w')}},
+	{"backward-kill-word", []keys.Key{{Code: keys.Backspace, Mods: keys.ModAlt}}},
+	{"kill-word", []keys.Key{alt('d')}},
+	{"yank", []keys.Key{ctrl('y')}},
+	{"yank-pop", []keys.Key{alt('y')}},
+
+	{"undo", []keys.Key{ctrl('_')}},
+	{"undo", []keys.Key{ctrl('x'), ctrl('u')}},
+	{"redo", []keys.Key{ctrl('^')}},
+}
+
+// viInsertBindings are the key bindings of the vi insert mode.
+var viInsertBindings = []keyBinding{
+	{"vi-movement-mode", []keys.Key{{Code: keys.Escape}}},
+	{"accept-line", []keys.Key{{Code: keys.Enter}}},
+	{"accept-line", []keys.Key{ctrl('j')}},
+	{"complete", []keys.Key{{Code: keys.Tab}}},
+	{"interrupt", []keys.Key{ctrl('c')}},
+	{"end-of-file", []keys.Key{ctrl('d')}},
+	{"clear-screen", []keys.Key{ctrl('l')}},
+
+	{"backward-char", []keys.Key{{Code: keys.Left}}},
+	{"forward-char", []keys.Key{{Code: keys.Right}}},
+	{"backward-word", []keys.Key{{Code: keys.Left, Mods: keys.ModCtrl}}},
+	{"forward-word", []keys.Key{{Code: keys.Right, Mods: keys.ModCtrl}}},
+	{"beginning-of-line", []keys.Key{{Code: keys.Home}}},
+	{"end-of-line", []keys.Key{{Code: keys.End}}},
+
+	{"previous-history", []keys.Key{{Code: keys.Up}}},
+	{"next-history", []keys.Key{{Code: keys.Down}}},
+	{"reverse-search-history", []keys.Key{ctrl('r')}},
+	{"forward-search-history", []keys.Key{ctrl('s')}},
+
+	{"backward-delete-char", []keys.Key{{Code: keys.Backspace}}},
+	{"backward-delete-char", []keys.Key{ctrl('h')}},
+	{"delete-char", []keys.Key{{Code: keys.Delete}}},
+	{"transpose-chars", []keys.Key{ctrl('t')}},
+	{"unix-line-discard", []keys.Key{ctrl('u')}},
+	{"unix-word-rubout", []keys.Key{ctrl('w')}},
+	{"yank", []keys.Key{ctrl('y')}},
+}
+
+// viCommandBindings are the key bindings of the vi command mode, besides of the
+// characters in viCommandKeys.
+var viCommandBindings = []keyBinding{
+	{"abort", []keys.Key{{Code: keys.Escape}}},
+	{"accept-line", []keys.Key{{Code: keys.Enter}}},
+	{"accept-line", []keys.Key{ctrl('j')}},
+	{"interrupt", []keys.Key{ctrl('c')}},
+	{"end-of-file", []keys.Key{ctrl('d')}},
+	{"clear-screen", []keys.Key{ctrl('l')}},
+
+	{"backward-char", []keys.Key{{Code: keys.Left}}},
+	{"forward-char", []keys.Key{{Code: keys.Right}}},
+	{"backward-word", []keys.Key{{Code: keys.Left, Mods: keys.ModCtrl}}},
+	{"forward-word", []keys.Key{{Code: keys.Right, Mods: keys.ModCtrl}}},
+	{"beginning-of-line", []keys.Key{{Code: keys.Home}}},
+	{"end-of-line", []keys.Key{{Code: keys.End}}},
+
+	{"previous-history", []keys.Key{{Code: keys.Up}}},
+	{"next-history", []keys.Key{{Code: keys.Down}}},
+	{"reverse-search-history", []keys.Key{ctrl('r')}},
+	{"forward-search-history", []keys.Key{ctrl('s')}},
+
+	{"vi-command", []keys.Key{{Code: keys.Backspace}}},
+	{"delete-char", []keys.Key{{Code: keys.Delete}}},
+}
+
+// viCommandKeys are the characters which run a command in the vi command mode.
+const viCommandKeys = "0123456789 hlwWbBeE^$|fFtT;,dcyxXsSDCYpPr~iaIAu.kj-+/?nN"
+
+// NewEmacsKeymap returns a keymap with the key bindings by default, which are
+// like the ones of the emacs mode in GNU Readline.
+func NewEmacsKeymap() *Keymap {
+	return newKeymapOf(emacsBindings)
+}
+
+// NewViInsertKeymap returns a keymap with the key bindings by default of the vi
+// insert mode, where Escape changes to the command mode.
+func NewViInsertKeymap() *Keymap {
+	return newKeymapOf(viInsertBindings)
+}
+
+// NewViCommandKeymap returns a keymap with the key bindings by default of the
+// vi command mode. The vi commands, with their counts and motions, are run by
+// the command "vi-command", which is bound to the first character of each one.
+func NewViCommandKeymap() *Keymap {
+	km := newKeymapOf(viCommandBindings)
+	km.insert = false
+
+	for _, r := range viCommandKeys {
+		if err := km.Bind("vi-command", keys.Key{Code: keys.Rune, Rune: r}); err != nil {
+			panic(err)
+		}
+	}
+	return km
+}
+
+// newKeymapOf returns a keymap with the given key bindings.
+func newKeymapOf(bindings []keyBinding) *Keymap {
+	km := NewKeymap()
+	for _, b := range bindings {
+		if err := km.Bind(b.command, b.seq...); err != nil {
+			panic(err)
+		}
+	}
+	return km
+}
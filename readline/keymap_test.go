@@ -0,0 +1,84 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"testing"
+
+	"github.com/tredoe/term/keys"
+)
+
+func TestKeymap(t *testing.T) {
+	km := NewKeymap()
+
+	if err := km.Bind("yank"); err != ErrEmptySeq {
+		t.Errorf("expected error %v, got %v", ErrEmptySeq, err)
+	}
+	if err := km.Bind("no-command", ctrl('y')); err == nil {
+		t.Error("expected error binding an unknown command")
+	}
+
+	if err := km.Bind("yank", ctrl('y')); err != nil {
+		t.Fatal(err)
+	}
+	if err := km.Bind("undo", ctrl('x'), ctrl('u')); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		seq     []keys.Key
+		command string
+		ok      bool
+	}{
+		{[]keys.Key{ctrl('y')}, "yank", true},
+		{[]keys.Key{ctrl('x'), ctrl('u')}, "undo", true},
+		{[]keys.Key{ctrl('x')}, "", false}, // prefix
+		{[]keys.Key{ctrl('x'), ctrl('y')}, "", false},
+		{[]keys.Key{ctrl('y'), ctrl('u')}, "", false},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		if command, ok := km.Lookup(tt.seq...); command != tt.command || ok != tt.ok {
+			t.Errorf("Lookup(%v): expected (%q, %v), got (%q, %v)",
+				tt.seq, tt.command, tt.ok, command, ok)
+		}
+	}
+
+	// A sequence which is prefix of another one replaces it.
+	c := km.Clone()
+	if err := c.Bind("redo", ctrl('x')); err != nil {
+		t.Fatal(err)
+	}
+	if command, ok := c.Lookup(ctrl('x')); command != "redo" || !ok {
+		t.Errorf("expected %q, got %q", "redo", command)
+	}
+	if _, ok := km.Lookup(ctrl('x'), ctrl('u')); !ok {
+		t.Error("the changes in the clone modified the original keymap")
+	}
+
+	km.Unbind(ctrl('x'), ctrl('u'))
+	if _, ok := km.Lookup(ctrl('x'), ctrl('u')); ok {
+		t.Error("expected sequence unbound")
+	}
+}
+
+func TestEmacsKeymap(t *testing.T) {
+	names := make(map[string]bool)
+	for _, name := range Commands() {
+		names[name] = true
+	}
+
+	km := NewEmacsKeymap()
+	for _, b := range emacsBindings {
+		if !names[b.command] {
+			t.Errorf("command %q not registered", b.command)
+		}
+		if command, ok := km.Lookup(b.seq...); command != b.command || !ok {
+			t.Errorf("Lookup(%v): expected %q, got %q", b.seq, b.command, command)
+		}
+	}
+}
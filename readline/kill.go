@@ -0,0 +1,104 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+// KillRingCap is the number of texts killed that are kept, by default.
+// If it is not positive then the texts killed are not kept.
+var KillRingCap = 10
+
+// A killRing represents the texts killed, which can be yanked later.
+type killRing struct {
+	texts [][]rune // The last one is the newest
+	idx   int      // Text to yank
+
+	// Position of the text yanked, relative to the prompt, to be replaced
+	// at rotating.
+	yankStart, yankEnd int
+}
+
+// add adds a text killed. If merge is true then the text is merged with the
+// last one killed, before of it if backward is true.
+func (k *killRing) add(text []rune, merge, backward bool) {
+	if len(text) == 0 {
+		return
+	}
+
+	if merge && len(k.texts) != 0 {
+		last := k.texts[len(k.texts)-1]
+		if backward {
+			last = append(append([]rune(nil), text...), last...)
+		} else {
+			last = append(last, text...)
+		}
+		k.texts[len(k.texts)-1] = last
+	} else if KillRingCap > 0 {
+		if n := len(k.texts) - KillRingCap + 1; n > 0 {
+			k.texts = k.texts[n:]
+		}
+		k.texts = append(k.texts, append([]rune(nil), text...))
+	}
+	k.idx = len(k.texts) - 1
+}
+
+// yank returns the text to yank; nil if there is not.
+func (k *killRing) yank() []rune {
+	if len(k.texts) == 0 {
+		return nil
+	}
+	return k.texts[k.idx]
+}
+
+// rotate moves to the previous text killed, returning it.
+func (k *killRing) rotate() []rune {
+	if len(k.texts) == 0 {
+		return nil
+	}
+	if k.idx--; k.idx < 0 {
+		k.idx = len(k.texts) - 1
+	}
+	return k.texts[k.idx]
+}
+
+// == Commands
+//
+
+// kill kills the text between the positions start and end, relative to the
+// prompt. The text is merged with the last one killed if the previous command
+// was a kill too.
+func (ln *Line) kill(start, end int, merge bool) error {
+	if start == end {
+		return nil
+	}
+	backward := start < ln.buf.pos-ln.buf.promptLen
+
+	ln.killRing.add(ln.buf.text(start, end), merge, backward)
+	return ln.buf.replace(start, end, nil)
+}
+
+// yank inserts the last text killed at the cursor position.
+func (ln *Line) yank() error {
+	text := ln.killRing.yank()
+	if text == nil {
+		return nil
+	}
+
+	pos := ln.buf.pos - ln.buf.promptLen
+	ln.killRing.yankStart, ln.killRing.yankEnd = pos, pos+len(text)
+	return ln.buf.replace(pos, pos, text)
+}
+
+// yankPop replaces the text just yanked by the previous one killed.
+func (ln *Line) yankPop() error {
+	text := ln.killRing.rotate()
+	if text == nil {
+		return nil
+	}
+
+	start := ln.killRing.yankStart
+	ln.killRing.yankEnd = start + len(text)
+	return ln.buf.replace(start, ln.buf.pos-ln.buf.promptLen, text)
+}
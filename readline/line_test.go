@@ -0,0 +1,957 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// +build linux
+
+package readline
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tredoe/term"
+	"github.com/tredoe/term/keys"
+)
+
+// A ptyLine represents a line read from the slave of a pseudo-terminal, whose
+// master is used to send the keys and to get the output.
+type ptyLine struct {
+	*Line
+	t      *testing.T
+	master *os.File
+	slave  *os.File
+
+	mu   sync.Mutex
+	out  bytes.Buffer
+	done chan struct{} // Closed when the output is not read any more
+}
+
+func newPtyLine(t *testing.T, columns int, hist *StoredHistory) *ptyLine {
+	master, slave, err := term.OpenPTY()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = term.SetSize(int(master.Fd()), 24, columns); err != nil {
+		t.Fatal(err)
+	}
+	ter, err := term.NewFromFile(slave, slave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := NewLine(ter, PS1, PS2, hist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &ptyLine{Line: ln, t: t, master: master, slave: slave, done: make(chan struct{})}
+
+	// The output has to be read so the writes in the slave do not block.
+	go func() {
+		defer close(p.done)
+		buf := make([]byte, 1024)
+		for {
+			n, err := master.Read(buf)
+			p.mu.Lock()
+			p.out.Write(buf[:n])
+			p.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// close restores the terminal and closes the pseudo-terminal.
+func (p *ptyLine) close() {
+	if err := p.Restore(); err != nil {
+		p.t.Error(err)
+	}
+	p.slave.Close()
+	p.master.Close()
+	<-p.done
+}
+
+// send writes the keys pressed into the master.
+func (p *ptyLine) send(keys string) {
+	if _, err := io.WriteString(p.master, keys); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// read sends the keys and returns the line read.
+func (p *ptyLine) read(keys string) string {
+	p.send(keys)
+
+	line, err := p.Read()
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return line
+}
+
+// waitOutput waits until the output contains s, returning the output got.
+func (p *ptyLine) waitOutput(s string) string {
+	for i := 0; i < 100; i++ {
+		p.mu.Lock()
+		out := p.out.String()
+		p.mu.Unlock()
+
+		if strings.Contains(out, s) {
+			return out
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	p.t.Fatalf("expected output to contain %q", s)
+	return ""
+}
+
+func TestLineRead(t *testing.T) {
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"hello\r", "hello"},
+		{"helo\x1b[Dl\r", "hello"},            // left arrow
+		{"ello\x01h\r", "hello"},              // Ctrl+a
+		{"hello world\x15bye\r", "bye"},       // Ctrl+u
+		{"hello\x1b[D\x1b[D\x0b\r", "hel"},    // Ctrl+k
+		{"hlelo\x02\x02\x02\x14\r", "hello"},  // Ctrl+t
+		{"hello!\x7f\r", "hello"},             // backspace
+		{"hello\x1b[1;5D\x1b[3~\r", "ello"},   // Ctrl+left arrow, delete
+		{"a b\x1bb\x1bb\x1b[1;5Cc\r", "ac b"}, // Alt+b, Ctrl+right arrow
+		{"hel\x1bOHo\x1b[4~!\r", "ohel!"},     // Home, End
+	}
+
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+}
+
+func TestLineComplete(t *testing.T) {
+	words := []string{"hello", "help", "world"}
+	for i := 0; i < 30; i++ {
+		words = append(words, "item"+string(rune('a'+i%26))+string(rune('a'+i/26)))
+	}
+
+	p := newPtyLine(t, 40, nil)
+	defer p.close()
+
+	p.SetCompleter(CompleterFunc(func(line string, pos int) ([]string, int, int) {
+		start := strings.LastIndex(line[:pos], " ") + 1
+		var c []string
+		for _, w := range words {
+			if strings.HasPrefix(w, line[start:pos]) {
+				c = append(c, w)
+			}
+		}
+		return c, start, pos
+	}))
+
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"wo\t\r", "world"},
+		{"wo\tis\r", "world is"},
+		{"say he\t\r", "say hel"},
+		{"he\tp\r", "help"},
+		{"he\t\x01x\x05lo\r", "xhello"},
+		{"x\tyz\r", "xyz"},
+	}
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+
+	// Pressing Tab twice lists the candidates.
+	if line := p.read("hel\t\t\r"); line != "hel" {
+		t.Errorf("expected %q, got %q", "hel", line)
+	}
+	p.waitOutput("hello  help\r\n")
+
+	// The whole prompt is written again after of the candidates.
+	p.ps1 = "HEAD\n" + PS1
+	if line := p.read("hel\t\t\r"); line != "hel" {
+		t.Errorf("expected %q, got %q", "hel", line)
+	}
+	p.waitOutput("hello  help\r\nHEAD\r\n\r" + PS1 + "hel")
+	p.ps1 = PS1
+
+	// The pager stops at pressing 'q'.
+	if err := term.SetSize(int(p.master.Fd()), 5, 40); err != nil {
+		t.Fatal(err)
+	}
+	if line := p.read("item\t\tq\r"); line != "item" {
+		t.Errorf("expected %q, got %q", "item", line)
+	}
+	out := p.waitOutput("--More--")
+	if !strings.Contains(out, "itemda") || strings.Contains(out, "itemea") {
+		t.Error("expected to stop the pager")
+	}
+}
+
+func TestLineHistory(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPtyLine(t, 80, hist)
+	defer p.close()
+
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"one\r", "one"},
+		{"two\r", "two"},
+		{"\x1b[A\x1b[A\r", "one"},
+		{"x\x1b[A\x1b[A\x1b[B\x1b[B\x1b[B\r", "x"}, // back to the line being edited
+	}
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+	var lines []string
+	for _, e := range hist.Last(hist.Len()) {
+		lines = append(lines, e.Line)
+	}
+	if got := strings.Join(lines, ","); got != "one,two,one,x" {
+		t.Errorf("expected lines accepted in history, got %q", got)
+	}
+}
+
+func TestLineHistorySearch(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPtyLine(t, 80, hist)
+	defer p.close()
+	p.SetHistoryPrefixSearch(true)
+
+	for _, s := range []string{"echo one", "ls -l", "echo two"} {
+		p.read(s + "\r")
+	}
+
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"ec\x1b[A\r", "echo two"},
+		{"ec\x1b[A\x1b[A\r", "echo one"},       // the duplicates are skipped
+		{"ec\x1b[A\x1b[Ax\r", "ecxho two"},     // the cursor is kept
+		{"ec\x1b[A\x1b[A\x1b[B\x1b[B\r", "ec"}, // back to the line being edited
+		{"zz\x1b[A\r", "zz"},                   // not found
+		{"\x1b[A\r", "zz"},                     // without prefix
+	}
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+}
+
+func TestLineSearch(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPtyLine(t, 80, hist)
+	defer p.close()
+
+	for _, s := range []string{"echo one", "ls -l", "echo two"} {
+		p.read(s + "\r")
+	}
+
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"\x12ec\r", "echo two"},
+		{"\x12ec\x12\r", "echo one"},            // Ctrl+r again
+		{"abc\x12ls\x07\r", "abc"},              // Ctrl+g
+		{"\x12-l\x01x\r", "xls -l"},             // accepted by Ctrl+a
+		{"\x12zz\r", ""},                        // failed
+		{"\x12echo\x12\x13\r", "echo one"},      // Ctrl+s
+		{"\x12two\x1b[C\x1b[C!\r", "echo tw!o"}, // accepted by right arrow
+	}
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+	p.waitOutput("(failed reverse-i-search)`zz': ")
+
+	// Escape accepts the match, once the escape sequence timeout is elapsed.
+	done := make(chan string)
+	go func() {
+		line, err := p.Read()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- line
+	}()
+	p.send("\x12lsx\x7f\x1b")
+	time.Sleep(4 * keys.EscapeTimeout)
+	p.send("\r")
+
+	if line := <-done; line != "xls -l" {
+		t.Errorf("expected %q, got %q", "xls -l", line)
+	}
+}
+
+func TestLineKill(t *testing.T) {
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"hello world\x17\x19\x19\r", "hello worldworld"}, // Ctrl+w, Ctrl+y
+		{"one two three\x17\x17\x19\r", "one two three"},  // killed in a row
+		{"hello\x01\x06\x0b\x01\x19\r", "elloh"},          // Ctrl+k
+		{"first\x15second\x15\x19\x1by\r", "first"},       // Alt+y
+		{"a b c\x01\x1bd\x1bd\x05\x19\r", "ca b"},         // Alt+d
+		{"x\x19\x1by\x1by\r", "xfirst"},                   // rotate
+		{"one two\x1b\x7f\x19\x19\r", "one twotwo"},       // Alt+Backspace
+	}
+
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+}
+
+func TestKillRing(t *testing.T) {
+	var k killRing
+
+	if k.yank() != nil || k.rotate() != nil {
+		t.Error("expected nothing to yank")
+	}
+	for i := 0; i < KillRingCap+2; i++ {
+		k.add([]rune{rune('a' + i)}, false, false)
+	}
+	if len(k.texts) != KillRingCap {
+		t.Errorf("expected %d texts, got %d", KillRingCap, len(k.texts))
+	}
+	if s := string(k.yank()); s != string(rune('a'+KillRingCap+1)) {
+		t.Errorf("expected to yank the last text, got %q", s)
+	}
+
+	k.add([]rune("1"), true, false)
+	k.add([]rune("0"), true, true)
+	if s := string(k.yank()); s != "0l1" {
+		t.Errorf("expected %q, got %q", "0l1", s)
+	}
+	if s := string(k.rotate()); s != "k" {
+		t.Errorf("expected %q, got %q", "k", s)
+	}
+
+	// Without kill ring.
+	defer func(n int) { KillRingCap = n }(KillRingCap)
+	KillRingCap = 0
+	k = killRing{}
+	k.add([]rune("a"), false, false)
+	if k.yank() != nil {
+		t.Error("expected nothing to yank")
+	}
+}
+
+func TestLineUndo(t *testing.T) {
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"hello\x1f\r", ""},                      // characters inserted in a row
+		{"hello world\x17\x1f\r", "hello world"}, // kill
+		{"abc\x15\x1f\x1f\r", ""},
+		{"ab\x01x\x1f\r", "ab"},
+		{"ab\x01x\x1f\x1e\r", "xab"},       // redo
+		{"ab\x14\x18\x15\r", "ab"},         // Ctrl+x Ctrl+u, swap
+		{"ab\x1f\x1f\x1e\x1e\x1e\r", "ab"}, // nothing more to undo or redo
+	}
+
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+}
+
+func TestLineKeymap(t *testing.T) {
+	RegisterCommand("test-upcase", func(ln *Line, _ keys.Key) error {
+		return ln.SetText(strings.ToUpper(ln.Text()), ln.Cursor())
+	})
+	defer RegisterCommand("test-upcase", nil)
+
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+
+	km := p.Keymap().Clone()
+	if err := km.Bind("test-upcase", alt('u')); err != nil {
+		t.Fatal(err)
+	}
+	if err := km.Bind("backward-char", ctrl('x'), ctrl('b')); err != nil {
+		t.Fatal(err)
+	}
+	km.Unbind(ctrl('t'))
+	p.SetKeymap(km)
+
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"hello\x1bu\r", "HELLO"},          // Alt+u
+		{"helo\x18\x02l\r", "hello"},       // Ctrl+x Ctrl+b
+		{"ab\x14\r", "ab"},                 // Ctrl+t unbound
+		{"ab\x18x\r", "ab"},                // Ctrl+x x not bound
+		{"ab\x1bu\x01\x1bu\x7fc\r", "cAB"}, // cursor kept
+	}
+
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+}
+
+func TestLineVi(t *testing.T) {
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"hello world\x1b0dw\r", "world"},
+		{"hello world\x1bbcwthere\r", "hello there"},
+		{"one two three\x1b02dw\r", "three"}, // count
+		{"one two three\x1b0d2w\r", "three"},
+		{"abcdef\x1b0fdD\r", "abc"},
+		{"abcdef\x1b0tdx\r", "abdef"},
+		{"a.b.c\x1b0f.;x\r", "a.bc"},
+		{"a.b.c\x1bF.,x\r", "a.bc"},
+		{"hello\x1bx.\r", "hel"},       // repeat
+		{"abc\x1b0ix\x1b.\r", "xxabc"}, // repeat insertion
+		{"hello\x1b0yw$p\r", "hellohello"},
+		{"hello\x1b03xu\r", "hello"}, // undo
+		{"hello world\x1bbcwthere\x1bu\r", "hello world"},
+		{"abc\x1b0r-\r", "-bc"},
+		{"abc\x1b0~~\r", "ABc"},
+		{"hello\x1b02ia-\x1b\r", "a-a-hello"},
+		{"abc def\x1bBcWx\r", "abc x"},
+		{"abc def\x1b0eD\r", "ab"},
+		{"abc\x1bIx\x1bAy\r", "xabcy"},
+		{"abc def\x1b0ccxyz\r", "xyz"},
+		{"abc\x1b0q\r", "abc"}, // unknown command
+	}
+
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+
+	if err := p.SetMode(ViInsertMode); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+
+	// The mode is shown through the prompt.
+	p.SetModePrompt(func(mode Mode) string {
+		if mode == ViCommandMode {
+			return ": "
+		}
+		return "+ "
+	})
+	if line := p.read("ab\x1bx\r"); line != "a" {
+		t.Errorf("expected %q, got %q", "a", line)
+	}
+	p.waitOutput(": a")
+
+	// Change to the emacs mode.
+	if err := p.SetMode(EmacsMode); err != nil {
+		t.Fatal(err)
+	}
+	if line := p.read("ab\x01c\r"); line != "cab" {
+		t.Errorf("expected %q, got %q", "cab", line)
+	}
+}
+
+func TestLineViSearch(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPtyLine(t, 80, hist)
+	defer p.close()
+
+	for _, s := range []string{"echo one", "ls -l", "echo two"} {
+		p.read(s + "\r")
+	}
+	if err := p.SetMode(ViInsertMode); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"\x1b/echo\r\r", "echo two"},
+		{"\x1b/echo\rn\r", "echo one"},
+		{"\x1b/ls\rx\r", "s -l"},
+		{"abc\x1b/\x7fx\r", "ab"}, // cancelled
+	}
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+}
+
+func TestLineWide(t *testing.T) {
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"\u65e5\u672c\u8a9e\x1b[D\x1b[D!\r", "\u65e5!\u672c\u8a9e"},
+		{"ae\u0301\x1b[Dx\r", "axe\u0301"},                                                   // combining accent
+		{"ae\u0301\x7f\r", "a"},                                                              // backspace
+		{"\u65e5\u672c\x14\r", "\u672c\u65e5"},                                               // swap
+		{"a\U0001F469\u200D\U0001F467b\x1b[D\x1b[D\x1b[3~\r", "ab"},                          // ZWJ sequence
+		{"ab\u65e5\u672c\u8a9ecd\x01\x1b[C\x1b[C\x1b[C\x1b[C?\r", "ab\u65e5\u672c?\u8a9ecd"}, // wrapped
+	}
+
+	p := newPtyLine(t, 6, nil)
+	defer p.close()
+
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+}
+
+func TestLinePrompt(t *testing.T) {
+	p := newPtyLine(t, 10, nil)
+	defer p.close()
+
+	p.ps1 = "\033[1;32muser\033[0m\n\001\033[34m\002$\001\033[0m\002 "
+	if line := p.read("hello world\x01\x06\x06x\r"); line != "hexllo world" {
+		t.Errorf("expected %q, got %q", "hexllo world", line)
+	}
+
+	out := p.waitOutput("world")
+	if !strings.Contains(out, "\033[1;32muser\033[0m\r\n\033[34m$\033[0m ") {
+		t.Errorf("prompt not written: %q", out)
+	}
+	if strings.ContainsAny(out, "\001\002") {
+		t.Errorf("markers written: %q", out)
+	}
+}
+
+func TestLineMultiline(t *testing.T) {
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"echo 'a\rb'\r", "echo 'a\nb'"},
+		{"ls \\\r-l\r", "ls \\\n-l"},
+		{"(1\r2)\x1b[Ax\r", "(1x\n2)"},                 // up
+		{"(abc\rd)\x1b[A\x1b[D\x1b[Bx\r", "(abc\ndx)"}, // down, to the nearest column
+		{"[a\rb]\x01X\x1b[A\x05Y\r", "[aY\nXb]"},       // start and end of line
+	}
+
+	p := newPtyLine(t, 20, nil)
+	defer p.close()
+	p.SetValidator(ValidatorFunc(IsBalanced))
+
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+	p.waitOutput("\033[0K\r\n" + PS2)
+}
+
+func TestLineHistoryExpansion(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPtyLine(t, 80, hist)
+	defer p.close()
+	p.SetHistoryExpansion(true)
+
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"ls -l\r", "ls -l"},
+		{"!!\r", "ls -l"},
+		{"echo !$\r", "echo -l"},
+		{"!nothing\r\x15ok\r", "ok"}, // the line is kept to edit it
+	}
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+	p.waitOutput("!nothing: event not found\r\n")
+
+	p.SetHistoryVerify(true)
+	if line := p.read("!e\rx\r"); line != "echo -lx" {
+		t.Errorf("expected line expanded to verify, got %q", line)
+	}
+	if got := strings.Join(histLines(hist.Last(hist.Len())), ","); got != "ls -l,ls -l,echo -l,ok,echo -lx" {
+		t.Errorf("expected lines expanded in history, got %q", got)
+	}
+}
+
+func TestLineHighlight(t *testing.T) {
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+	p.SetHighlighter(HighlighterFunc(highlightWords))
+	p.SetBracketHighlight("7")
+
+	if line := p.read("echo (a)\x1b[D\x08\r"); line != "echo ()" {
+		t.Errorf("expected %q, got %q", "echo ()", line)
+	}
+	p.waitOutput("\033[1mecho\033[0m\033[34m \033[0m\033[34;7m()\033[0m")
+	p.waitOutput("\033[34m ()\033[0m") // not highlighted once accepted
+}
+
+func TestLineSuggest(t *testing.T) {
+	hist, err := NewHistory(filepath.Join(t.TempDir(), "history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPtyLine(t, 80, hist)
+	defer p.close()
+	p.SetSuggester(HistorySuggester(hist))
+
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"echo one two\r", "echo one two"},
+		{"e\x1b[C\r", "echo one two"},   // Right
+		{"e\x1b[F\r", "echo one two"},   // End
+		{"e\x1bf\x1bfx\r", "echo onex"}, // Alt+f
+		{"ech\r", "ech"},                // not accepted
+	}
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+	p.waitOutput("ech\033[2mo onex\033[0m") // the last entry
+}
+
+func TestLineReadContext(t *testing.T) {
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.send("abc")
+	go func() {
+		p.waitOutput("abc")
+		cancel()
+	}()
+	if _, err := p.ReadContext(ctx); err != context.Canceled {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	p.waitOutput("abc\r\n")
+
+	p.SetIdleTimeout(50 * time.Millisecond)
+	if _, err := p.ReadContext(context.Background()); err != ErrIdleTimeout {
+		t.Fatalf("expected idle timeout, got %v", err)
+	}
+
+	// The keys pressed after are read.
+	p.SetIdleTimeout(0)
+	if line := p.read("d\r"); line != "d" {
+		t.Errorf("expected %q, got %q", "d", line)
+	}
+
+	// The input is not read once the line is restored.
+	p.SetIdleTimeout(50 * time.Millisecond)
+	if _, err := p.ReadContext(context.Background()); err != ErrIdleTimeout {
+		t.Fatalf("expected idle timeout, got %v", err)
+	}
+	if err := p.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	read := make(chan string)
+	go func() {
+		buf := make([]byte, 8)
+		n, _ := p.slave.Read(buf)
+		read <- string(buf[:n])
+	}()
+	p.send("x\n")
+	select {
+	case got := <-read:
+		if got != "x\n" {
+			t.Errorf("expected to read %q from the input, got %q", "x\n", got)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected to read from the input")
+	}
+}
+
+func TestLineWrite(t *testing.T) {
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+
+	done := make(chan string)
+	go func() {
+		line, err := p.Read()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- line
+	}()
+
+	p.send("abc\x1b[D")
+	p.waitOutput("abc\033[1D")
+	if _, err := p.Printf("log %d\nlog %d", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	p.waitOutput("\033[0Jlog 1\r\nlog 2\r\n\r" + PS1 + "abc\033[0J\r\033[4C")
+
+	p.send("x\r")
+	if line := <-done; line != "abxc" {
+		t.Errorf("expected %q, got %q", "abxc", line)
+	}
+
+	// Out of the reading.
+	if _, err := p.Printf("end\n"); err != nil {
+		t.Fatal(err)
+	}
+	p.waitOutput("end\r\n")
+}
+
+func TestLineWriteCommand(t *testing.T) {
+	RegisterCommand("test-print", func(ln *Line, _ keys.Key) error {
+		_, err := ln.Printf("info")
+		return err
+	})
+	defer RegisterCommand("test-print", nil)
+
+	p := newPtyLine(t, 40, nil)
+	defer p.close()
+
+	km := p.Keymap().Clone()
+	if err := km.Bind("test-print", ctrl('x'), keys.Key{Code: keys.Rune, Rune: 'p'}); err != nil {
+		t.Fatal(err)
+	}
+	p.SetKeymap(km)
+
+	// Written from a command.
+	if line := p.read("ab\x18pc\r"); line != "abc" {
+		t.Errorf("expected %q, got %q", "abc", line)
+	}
+	p.waitOutput("\033[0Jinfo\r\n\r" + PS1 + "ab")
+
+	// Written while the candidates are listed, once the pager is finished.
+	p.SetCompleter(CompleterFunc(func(line string, pos int) ([]string, int, int) {
+		var c []string
+		for i := 0; i < 20; i++ {
+			c = append(c, line+strings.Repeat("x", i))
+		}
+		return c, 0, pos
+	}))
+	if err := term.SetSize(int(p.master.Fd()), 5, 40); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan string)
+	go func() {
+		line, err := p.Read()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- line
+	}()
+	p.send("item\t\t")
+	p.waitOutput("--More--")
+
+	if _, err := p.Printf("log"); err != nil {
+		t.Fatal(err)
+	}
+	if err := term.SetSize(int(p.master.Fd()), 5, 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGWINCH); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	out := p.waitOutput("--More--")
+	if after := out[strings.LastIndex(out, "--More--"):]; after != "--More--" {
+		t.Errorf("expected nothing written into the pager, got %q", after)
+	}
+
+	p.send("q\r")
+	if line := <-done; line != "item" {
+		t.Errorf("expected %q, got %q", "item", line)
+	}
+	p.waitOutput("log\r\n\r" + PS1 + "item")
+
+	p.Line.mu.Lock()
+	columns := p.buf.columns
+	p.Line.mu.Unlock()
+	if columns != 30 {
+		t.Errorf("expected 30 columns, got %d", columns)
+	}
+}
+
+func TestLineInterrupt(t *testing.T) {
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+
+	p.send("ab\x03")
+	if line, err := p.Read(); err != ErrInterrupt || line != "ab" {
+		t.Errorf("expected interrupt with %q, got %q, %v", "ab", line, err)
+	}
+	p.waitOutput("ab^C\r\n")
+
+	p.send("\x04")
+	if _, err := p.Read(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if line := p.read("ab\x01\x04\r"); line != "b" {
+		t.Errorf("expected character deleted, got %q", line)
+	}
+
+	p.SetIgnoreInterrupt(true)
+	if line := p.read("ab\x03cd\r"); line != "cd" {
+		t.Errorf("expected text discarded, got %q", line)
+	}
+}
+
+// failingStore is a store whose entries can not be appended.
+type failingStore struct{ HistoryStore }
+
+func (failingStore) Append(Entry) error { return syscall.ENOSPC }
+
+func TestLineHistoryError(t *testing.T) {
+	hist, err := NewHistoryOfStore(failingStore{NewMemoryStore()}, HistoryCap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPtyLine(t, 80, hist)
+	defer p.close()
+
+	p.send("ls\r")
+	if line, err := p.Read(); err != syscall.ENOSPC || line != "ls" {
+		t.Errorf("expected history error with %q, got %q, %v", "ls", line, err)
+	}
+	if hist.Len() != 1 {
+		t.Errorf("expected line kept in history, got %d entries", hist.Len())
+	}
+}
+
+func TestLineResize(t *testing.T) {
+	p := newPtyLine(t, 20, nil)
+	defer p.close()
+
+	// A single goroutine is kept to detect the changes of size.
+	p.read("a\r")
+	n := runtime.NumGoroutine()
+	for i := 0; i < 3; i++ {
+		p.read("a\r")
+	}
+	if got := runtime.NumGoroutine(); got != n {
+		t.Errorf("expected %d goroutines, got %d", n, got)
+	}
+
+	done := make(chan string)
+	go func() {
+		line, err := p.Read()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- line
+	}()
+	p.send("0123456789abcdef")
+	p.waitOutput("abcdef")
+
+	if err := term.SetSize(int(p.master.Fd()), 24, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGWINCH); err != nil {
+		t.Fatal(err)
+	}
+	// The line is written again, with the cursor at the new position.
+	p.waitOutput("\r" + PS1 + "0123456789abcdef\033[0J\r\033[8C")
+
+	p.send("\r")
+	if line := <-done; line != "0123456789abcdef" {
+		t.Errorf("expected %q, got %q", "0123456789abcdef", line)
+	}
+	p.Line.mu.Lock()
+	columns := p.buf.columns
+	p.Line.mu.Unlock()
+	if columns != 10 {
+		t.Errorf("expected 10 columns, got %d", columns)
+	}
+}
+
+func TestLineWordPromptEnd(t *testing.T) {
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+	p.ps1 = "info\n" // The last line of the prompt is empty.
+
+	tests := []struct {
+		keys string
+		line string
+	}{
+		{"\x1bb\x1bfa\r", "a"},
+		{"ab cd\x1bb\x1bbX\r", "Xab cd"},
+		{"ab cd\x1bb\x1bb\x1bf\x1bfX\r", "ab cdX"},
+		{"ab é cd\x1bb\x1bbX\r", "ab Xé cd"},
+	}
+	for _, tt := range tests {
+		if line := p.read(tt.keys); line != tt.line {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.line, line)
+		}
+	}
+}
+
+func TestLineLong(t *testing.T) {
+	p := newPtyLine(t, 80, nil)
+	defer p.close()
+
+	text := strings.Repeat("abcd ", BufferCap/5+100)
+	go p.send(text + "\x15\x19\x19\r") // kill and yank twice
+	line, err := p.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.TrimSpace(text + text); line != want {
+		t.Errorf("expected %d characters, got %d", len(want), len(line))
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Write writes p above of the line being read, which is written again below,
+// so it can be called from other goroutines while Read is running. The new
+// lines are written like CR+LF, and it is added a new line at the end whether
+// there is not.
+//
+// While a command is running, like at showing the candidates of the
+// completion, p is written once it finishes, so it can be called from a
+// command too.
+func (ln *Line) Write(p []byte) (n int, err error) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	text := bytes.Replace(p, []byte("\n"), CRLF, -1)
+	if !bytes.HasSuffix(text, CRLF) {
+		text = append(text, CRLF...)
+	}
+
+	if ln.busy {
+		ln.pending = append(ln.pending, text...)
+		return len(p), nil
+	}
+	if err = ln.writeAbove(text); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writePending writes the output got while a command was running.
+func (ln *Line) writePending() error {
+	if len(ln.pending) == 0 {
+		return nil
+	}
+	text := ln.pending
+	ln.pending = nil
+	return ln.writeAbove(text)
+}
+
+// writeAbove writes the text above of the line, whether it is being read.
+func (ln *Line) writeAbove(text []byte) error {
+	out := ln.ter.Output()
+
+	if !ln.reading {
+		if _, err := out.Write(text); err != nil {
+			return outputError(err.Error())
+		}
+		return nil
+	}
+
+	if err := ln.clear(); err != nil {
+		return err
+	}
+	if _, err := out.Write(text); err != nil {
+		return outputError(err.Error())
+	}
+	if err := ln.promptHead(); err != nil {
+		return err
+	}
+	return ln.buf.redraw(0)
+}
+
+// Printf formats according to a format specifier and writes the result above
+// of the line being read, like Write.
+func (ln *Line) Printf(format string, a ...interface{}) (n int, err error) {
+	return fmt.Fprintf(ln, format, a...)
+}
+
+// clear clears the line being read, together with the lines of the primary
+// prompt before of the last one, leaving the cursor at the first column.
+func (ln *Line) clear() error {
+	head, _ := ln.prompt()
+	line, _ := ln.buf.pos2xy(ln.buf.pos)
+
+	out := ln.ter.Output()
+	for n := line + strings.Count(head, "\n"); n > 0; n-- {
+		if _, err := out.Write(ToPreviousLine); err != nil {
+			return outputError(err.Error())
+		}
+	}
+	if _, err := out.Write(CR); err != nil {
+		return outputError(err.Error())
+	}
+	if _, err := out.Write(DelToDown); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
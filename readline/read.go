@@ -6,7 +6,15 @@
 
 package readline
 
-import "github.com/tredoe/term"
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tredoe/term"
+	"github.com/tredoe/term/keys"
+)
 
 // Default values for prompts.
 const (
@@ -14,43 +22,103 @@ const (
 	PS2 = "> "
 )
 
+// A Mode represents the editing mode of a line.
+type Mode int
+
+const (
+	EmacsMode     Mode = iota // Like in Emacs; the mode by default
+	ViInsertMode              // Insert mode of vi
+	ViCommandMode             // Command mode of vi
+)
+
+// A BellStyle represents how the bell is rung.
+type BellStyle int
+
+const (
+	BellAudible BellStyle = iota // Sound; the style by default
+	BellVisible                  // Flash of the screen
+	BellNone                     // Ignored
+)
+
 // keyAction represents the action to run for a key or sequence of keys pressed.
 type keyAction int
 
 const (
 	_ keyAction = iota
-	_LEFT
-	_RIGHT
-	_UP
-	_DOWN
-
-	_HOME
-	_END
+	_INSERT
+	_KILL
+	_YANK
+	_UNDO
+	_COMPLETE
+	_HISTORY_SEARCH
 )
 
-// To detect if has been pressed CTRL-C
-var ChanCtrlC = make(chan byte)
+// ctrl returns the key of the letter r pressed together with Ctrl.
+func ctrl(r rune) keys.Key {
+	return keys.Key{Code: keys.Rune, Rune: r, Mods: keys.ModCtrl}
+}
 
-// To detect if has been pressed CTRL-D
-var ChanCtrlD = make(chan byte)
+// alt returns the key of the character r pressed together with Alt.
+func alt(r rune) keys.Key {
+	return keys.Key{Code: keys.Rune, Rune: r, Mods: keys.ModAlt}
+}
 
 // A Line represents a line in the term.
 type Line struct {
 	ter  *term.Terminal
-	buf  *buffer  // Text buffer
-	hist *history // History file
+	in   *keys.Reader // Keys pressed
+	buf  *buffer      // Text buffer
+	hist History      // Lines read
+
+	mode       Mode
+	keymaps    [ViCommandMode + 1]*Keymap // Key bindings for each mode
+	modePrompt func(Mode) string          // Prompt for each mode
+	vi         viState
 
-	ps1    string // Primary prompt
-	ps2    string // Command continuations
-	lenPS1 int    // Size of primary prompt
+	ps1 string // Primary prompt
+	ps2 string // Command continuations
+
+	appName   string    // Name of the application, for the init file
+	initFile  string    // Last init file read
+	bellStyle BellStyle // How the bell is rung
+
+	ignoreInterrupt bool // If Ctrl+c discards the text instead of returning
+
+	prefixSearch bool          // If the history is walked by the text before of the cursor
+	idleTimeout  time.Duration // Time to wait for a key; 0 is without limit
+	histExpand   bool          // If the references to the history are expanded
+	histVerify   bool          // If the lines expanded are edited before of accepting them
+
+	completer  Completer
+	validator  Validator
+	ignoreCase bool     // If the case is ignored in the completion
+	lastSearch string   // Last query used in the incremental search
+	killRing   killRing // Texts killed
+	undos      undoList // Changes to undo
+
+	// State of the line being read.
+	line         string          // Line accepted
+	accepted     bool            // If the line has been accepted
+	action, last keyAction       // Actions of the current and the last command
+	reading      bool            // If the line is being read
+	ctx          context.Context // Context of the reading
+	histPos      int             // Position of the history entry shown
+	scratch      string          // Line edited before of moving in history
+	histPrefix   string          // Prefix used in the last history search
 
 	useHistory bool
+
+	mu      sync.Mutex    // Serializes the reading, the resizing and Write
+	busy    bool          // If a command is running, without the lock
+	pending []byte        // Output written while a command is running
+	resized bool          // If the window size changed while a command was running
+	winSize *term.WinSize // Changes of the window size
 }
 
 // NewDefaultLine returns a line type using the prompt by default, and setting
 // the terminal to raw mode.
 // If the history is nil then it is not used.
-func NewDefaultLine(hist *history) (*Line, error) {
+func NewDefaultLine(hist History) (*Line, error) {
 	ter, err := term.New()
 	if err != nil {
 		return nil, err
@@ -64,23 +132,154 @@ func NewDefaultLine(hist *history) (*Line, error) {
 		return nil, err
 	}
 
-	buf := newBuffer(len(PS1), col)
-	buf.insertRunes([]rune(PS1))
+	buf := newBuffer(ter.Output(), col)
+	buf.reset([]rune(PS1))
+	buf.setContPrompt([]rune(PS2))
 
 	return &Line{
 		ter:  ter,
 		buf:  buf,
 		hist: hist,
 
-		ps1:    PS1,
-		ps2:    PS2,
-		lenPS1: len(PS1),
+		keymaps: newKeymaps(),
+
+		ps1: PS1,
+		ps2: PS2,
 
 		useHistory: hasHistory(hist),
 	}, nil
 }
 
+// newKeymaps returns the keymaps by default for each mode.
+func newKeymaps() [ViCommandMode + 1]*Keymap {
+	return [...]*Keymap{
+		EmacsMode:     NewEmacsKeymap(),
+		ViInsertMode:  NewViInsertKeymap(),
+		ViCommandMode: NewViCommandKeymap(),
+	}
+}
+
+// Keymap returns the key bindings used by the line in the actual mode.
+func (ln *Line) Keymap() *Keymap { return ln.keymaps[ln.mode] }
+
+// SetKeymap sets the key bindings to use by the line in the actual mode.
+func (ln *Line) SetKeymap(km *Keymap) { ln.keymaps[ln.mode] = km }
+
+// Mode returns the editing mode.
+func (ln *Line) Mode() Mode { return ln.mode }
+
+// SetMode sets the editing mode, which can be changed while the line is being
+// read. The mode ViCommandMode is changed to ViInsertMode at starting to read
+// a line.
+func (ln *Line) SetMode(mode Mode) error {
+	ln.mode = mode
+	return ln.updatePrompt()
+}
+
+// SetModePrompt sets a function which returns the prompt to show for each
+// mode, instead of the primary one, so the mode is indicated.
+// The prompt is redrawn every time that the mode is changed.
+func (ln *Line) SetModePrompt(f func(mode Mode) string) {
+	ln.modePrompt = f
+}
+
+// prompt returns the primary prompt to show in the actual mode, split in the
+// lines before of the last one, and the last one, which is shown together with
+// the text.
+func (ln *Line) prompt() (head, last string) {
+	ps1 := ln.ps1
+	if ln.modePrompt != nil {
+		ps1 = ln.modePrompt(ln.mode)
+	}
+
+	i := strings.LastIndexByte(ps1, '\n')
+	return ps1[:i+1], ps1[i+1:]
+}
+
+// updatePrompt redraws the line with the prompt of the actual mode, whether
+// the line is being read.
+func (ln *Line) updatePrompt() error {
+	if ln.modePrompt == nil || !ln.reading {
+		return nil
+	}
+	_, ps1 := ln.prompt()
+	return ln.buf.set([]rune(ps1), ln.buf.text(0, ln.buf.size-ln.buf.promptLen), ln.Cursor())
+}
+
+// SetHistoryPrefixSearch sets whether the commands previous-history and
+// next-history, bound to the up and down arrows, recall only the history
+// entries which start with the text before of the cursor, keeping the cursor
+// after of that text, like history-search-backward and history-search-forward.
+func (ln *Line) SetHistoryPrefixSearch(enabled bool) {
+	ln.prefixSearch = enabled
+}
+
+// SetIgnoreInterrupt sets whether Ctrl+c discards the text, showing the prompt
+// again, instead of returning ErrInterrupt.
+func (ln *Line) SetIgnoreInterrupt(ignore bool) {
+	ln.ignoreInterrupt = ignore
+}
+
+// SetBellStyle sets how the bell is rung.
+func (ln *Line) SetBellStyle(style BellStyle) {
+	ln.bellStyle = style
+}
+
+// SetIdleTimeout sets the time to wait for a key pressed, after of which
+// ReadContext returns ErrIdleTimeout.
+// If d is 0 then it is waited without limit.
+func (ln *Line) SetIdleTimeout(d time.Duration) {
+	ln.idleTimeout = d
+}
+
+// readKey reads the next key, until the context of the reading is done or the
+// idle timeout is expired.
+func (ln *Line) readKey() (keys.Key, error) {
+	ctx := ln.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	idle := ctx
+	if ln.idleTimeout > 0 {
+		var cancel context.CancelFunc
+		idle, cancel = context.WithTimeout(ctx, ln.idleTimeout)
+		defer cancel()
+	}
+
+	// The output can be written by Write while a key is waited for, but not
+	// into a command, like at showing the candidates of the completion.
+	var key keys.Key
+	var err error
+	if ln.busy {
+		key, err = ln.in.ReadKeyContext(idle)
+	} else {
+		ln.mu.Unlock()
+		key, err = ln.in.ReadKeyContext(idle)
+		ln.mu.Lock()
+	}
+
+	switch {
+	case err == nil:
+		return key, nil
+	case ctx.Err() != nil:
+		return key, ctx.Err()
+	case idle.Err() != nil:
+		return key, ErrIdleTimeout
+	}
+	return key, inputError(err.Error())
+}
+
 // Restore restores the terminal settings, so it is disabled the raw mode.
+// It also stops the reading of keys from the input, and the detection of the
+// changes of the window size.
 func (ln *Line) Restore() error {
+	if ln.in != nil {
+		ln.in.Close()
+		ln.in = nil
+	}
+	if ln.winSize != nil {
+		ln.winSize.Close()
+		ln.winSize = nil
+	}
 	return ln.ter.Restore()
 }
@@ -9,12 +9,11 @@
 package readline
 
 import (
-	"bufio"
-	"fmt"
+	"context"
 	"strings"
 
 	"github.com/tredoe/term"
-	"github.com/tredoe/term/sys"
+	"github.com/tredoe/term/keys"
 )
 
 func init() {
@@ -25,32 +24,34 @@ func init() {
 
 // NewLine returns a line using both prompts ps1 and ps2, and setting the given
 // terminal to raw mode, if were necessary.
-// lenAnsi is the length of ANSI codes that the prompt ps1 could have.
+// The prompts can have ANSI escape sequences, like colors, which are not
+// counted in their width, and several lines.
 // If the history is nil then it is not used.
-func NewLine(ter *term.Terminal, ps1, ps2 string, lenAnsi int, hist *history) (*Line, error) {
+func NewLine(ter *term.Terminal, ps1, ps2 string, hist History) (*Line, error) {
 	if ter.Mode()&term.RawMode == 0 { // the raw mode is not set
 		if err := ter.RawMode(); err != nil {
 			return nil, err
 		}
 	}
 
-	lenPS1 := len(ps1) - lenAnsi
 	_, col, err := ter.GetSize()
 	if err != nil {
 		return nil, err
 	}
 
-	buf := newBuffer(lenPS1, col)
-	buf.insertRunes([]rune(ps1))
+	buf := newBuffer(ter.Output(), col)
+	buf.reset([]rune(ps1[strings.LastIndexByte(ps1, '\n')+1:]))
+	buf.setContPrompt([]rune(ps2))
 
 	return &Line{
 		ter:  ter,
 		buf:  buf,
 		hist: hist,
 
-		ps1:    ps1,
-		ps2:    ps2,
-		lenPS1: lenPS1,
+		keymaps: newKeymaps(),
+
+		ps1: ps1,
+		ps2: ps2,
 
 		useHistory: hasHistory(hist),
 	}, nil
@@ -58,288 +59,252 @@ func NewLine(ter *term.Terminal, ps1, ps2 string, lenAnsi int, hist *history) (*
 
 // Prompt prints the primary prompt.
 func (ln *Line) Prompt() (err error) {
-	if _, err = term.Output.Write(DelLine_CR); err != nil {
+	_, ps1 := ln.prompt()
+
+	if _, err = ln.ter.Output().Write(DelLine_CR); err != nil {
 		return outputError(err.Error())
 	}
-	if _, err = fmt.Fprint(term.Output, ln.ps1); err != nil {
-		return outputError(err.Error())
+	if err = ln.promptHead(); err != nil {
+		return err
 	}
 
-	ln.buf.pos, ln.buf.size = ln.lenPS1, ln.lenPS1
-	return
+	ln.buf.reset([]rune(ps1))
+	if _, err = ln.ter.Output().Write(ln.buf.toBytes()); err != nil {
+		return outputError(err.Error())
+	}
+	return ln.buf.wrap()
 }
 
-// Read reads charactes from input to write them to output, enabling line editing.
-// The errors that could return are to indicate if Ctrl+D was pressed, and for
-// both input/output errors.
-func (ln *Line) Read() (line string, err error) {
-	var anotherLine []rune // For lines got from history.
-	var isHistoryUsed bool // If the history has been accessed.
-	var action keyAction
+// headReplacer replaces the new lines of the prompt for raw mode, and removes
+// the markers of characters which are not shown.
+var headReplacer = strings.NewReplacer("\n", "\r\n", "\001", "", "\002", "")
 
-	in := bufio.NewReader(term.Input) // Read input.
-	esc := make([]byte, 2)            // For escape sequences.
-	extEsc := make([]byte, 3)         // Extended escape sequences.
+// promptHead prints the lines of the primary prompt before of the last one.
+func (ln *Line) promptHead() error {
+	head, _ := ln.prompt()
+	if head == "" {
+		return nil
+	}
+	if _, err := headReplacer.WriteString(ln.ter.Output(), head); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
 
-	// Print the primary prompt.
-	if err = ln.Prompt(); err != nil {
-		return "", err
+// writeAgain writes the line, together with the whole prompt, from the start
+// of the line where the cursor is, like after of writing below of it.
+func (ln *Line) writeAgain() error {
+	if err := ln.promptHead(); err != nil {
+		return err
 	}
+	return ln.buf.refreshFrom(0)
+}
 
-	// == Detect change of window size.
-	winSize := term.DetectWinSize()
+// watchResize writes the line again, wrapped to the new width, whenever the
+// size of the window changes, until the function returned is called.
+func (ln *Line) watchResize() (stop func()) {
+	if ln.winSize == nil {
+		ln.winSize = term.DetectWinSize()
+	}
+	change := ln.winSize.Change
+	done, finished := make(chan bool), make(chan bool)
 
 	go func() {
+		defer close(finished)
 		for {
 			select {
-			case <-winSize.Change: // Wait for.
-				_, col, err := ln.ter.GetSize()
-				if err != nil {
-					ln.buf.columns = col
-					ln.buf.refresh()
-				}
+			case <-change:
+				ln.resize() // The errors are got at writing the next change.
+			case <-done:
+				return
 			}
 		}
 	}()
-	defer winSize.Close()
 
-	for ; ; action = 0 {
-		char, _, err := in.ReadRune()
-		if err != nil {
-			return "", inputError(err.Error())
-		}
+	return func() {
+		close(done)
+		<-finished
+	}
+}
 
-	_S:
-		switch char {
-		default:
-			if err = ln.buf.insertRune(char); err != nil {
-				return "", err
-			}
-			continue
+// resize sets the width of the window, or else once the command running, if
+// any, finishes.
+func (ln *Line) resize() error {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
 
-		case sys.K_RETURN:
-			line = ln.buf.toString()
+	if ln.busy {
+		ln.resized = true
+		return nil
+	}
+	return ln.setColumns()
+}
 
-			if ln.useHistory {
-				ln.hist.Add(line)
-			}
-			if _, err = term.Output.Write(CRLF); err != nil {
-				return "", outputError(err.Error())
-			}
-			return strings.TrimSpace(line), nil
+// setColumns sets the width of the window, writing the line again whether it
+// is being read. The cursor is placed at the line where it was before of the
+// change, so the text is wrapped from there.
+func (ln *Line) setColumns() error {
+	_, col, err := ln.ter.GetSize()
+	if err != nil {
+		return err
+	}
+	if col == ln.buf.columns {
+		return nil
+	}
+	oldLine, _ := ln.buf.pos2xy(ln.buf.pos)
+	ln.buf.columns = col
 
-		case sys.K_TAB:
-			// TODO: disabled by now
-			continue
+	if !ln.reading {
+		return nil
+	}
+	return ln.buf.redraw(oldLine)
+}
 
-		case sys.K_BACK, sys.K_CTRL_H:
-			if err = ln.buf.deleteCharPrev(); err != nil {
-				return "", err
-			}
-			continue
+// runCommand runs the command without the lock, so it can call Write. The
+// output written and the change of size got meanwhile are handled once it
+// finishes, since the screen could be not showing the line, like at listing
+// the candidates of the completion.
+func (ln *Line) runCommand(cmd Command, key keys.Key) error {
+	ln.busy = true
+	ln.mu.Unlock()
+	err := cmd(ln, key)
+	ln.mu.Lock()
+	ln.busy = false
+
+	if err != nil || ln.accepted {
+		return err // The output is written at finishing the reading.
+	}
+	if ln.resized {
+		ln.resized = false
+		if err = ln.setColumns(); err != nil {
+			return err
+		}
+	}
+	return ln.writePending()
+}
 
-		case sys.K_CTRL_C:
-			if err = ln.buf.insertRunes(CtrlC); err != nil {
-				return "", err
-			}
-			if _, err = term.Output.Write(CRLF); err != nil {
-				return "", outputError(err.Error())
-			}
+// leave moves the cursor to the line after of the text, without the hints.
+func (ln *Line) leave() error {
+	if _, err := ln.buf.end(); err != nil {
+		return err
+	}
+	if err := ln.buf.hideHints(); err != nil {
+		return err
+	}
+	if _, err := ln.ter.Output().Write(CRLF); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
 
-			ChanCtrlC <- 1 //TODO: is really necessary?
+// bell rings the bell, according to the bell style.
+func (ln *Line) bell() error {
+	var bell []byte
+	switch ln.bellStyle {
+	case BellNone:
+		return nil
+	case BellVisible:
+		bell = VisibleBell
+	default:
+		bell = Bell
+	}
 
-			if err = ln.Prompt(); err != nil {
-				return "", err
-			}
-			continue
-		case sys.K_CTRL_D:
-			if err = ln.buf.insertRunes(CtrlD); err != nil {
-				return "", err
-			}
-			if _, err = term.Output.Write(CRLF); err != nil {
-				return "", outputError(err.Error())
-			}
+	if _, err := ln.ter.Output().Write(bell); err != nil {
+		return outputError(err.Error())
+	}
+	return nil
+}
 
-			ln.Restore()
-			ChanCtrlD <- 1
-			return "", ErrCtrlD
+// Read reads charactes from input to write them to output, enabling line editing.
+// It returns io.EOF at pressing Ctrl+d in an empty line, and ErrInterrupt at
+// pressing Ctrl+c, together with the text written; the rest of errors are for
+// both input/output errors, except the one at adding the line to the history,
+// which is returned together with the line.
+func (ln *Line) Read() (line string, err error) {
+	return ln.ReadContext(context.Background())
+}
 
-		// Escape sequence
-		case sys.K_ESCAPE: // Ctrl+[ ("\x1b" in hexadecimal, "033" in octal)
-			if _, err = in.Read(esc); err != nil {
-				return "", inputError(err.Error())
-			}
+// ReadContext is like Read, but it returns the error of the context when it is
+// done, or ErrIdleTimeout when no key is pressed during the idle timeout (see
+// Line.SetIdleTimeout). Then, the cursor is moved to the next line, leaving
+// the text written in the screen.
+//
+// The read from the input which is in progress is interrupted whether the
+// input can be polled, like a terminal opened as a file, so no byte is got out
+// of a call; else, it is kept for the next call, and the goroutine which reads
+// finishes at Restore, after of that read.
+func (ln *Line) ReadContext(ctx context.Context) (line string, err error) {
+	// The resizing is stopped after of unlocking, since it locks the line.
+	defer ln.watchResize()()
+
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	if ln.in == nil {
+		ln.in = keys.NewReader(ln.ter.Input())
+	}
+	if ln.mode == ViCommandMode {
+		ln.mode = ViInsertMode
+	}
+	ln.vi.pending = nil
 
-			if esc[0] == 79 { // 'O'
-				switch esc[1] {
-				case 72: // Home: "\x1b O H"
-					action = _HOME
-					break _S
-				case 70: // End: "\x1b O F"
-					action = _END
-					break _S
-				}
-			}
+	// The size could be changed since the last reading.
+	if _, col, err := ln.ter.GetSize(); err == nil {
+		ln.buf.columns = col
+	}
 
-			if esc[0] == 91 { // '['
-				switch esc[1] {
-				case 65: // Up: "\x1b [ A"
-					if !ln.useHistory {
-						continue
-					}
-					action = _UP
-					break _S
-				case 66: // Down: "\x1b [ B"
-					if !ln.useHistory {
-						continue
-					}
-					action = _DOWN
-					break _S
-				case 68: // "\x1b [ D"
-					action = _LEFT
-					break _S
-				case 67: // "\x1b [ C"
-					action = _RIGHT
-					break _S
-				}
-
-				// Extended escape.
-				if esc[1] > 48 && esc[1] < 55 {
-					if _, err = in.Read(extEsc); err != nil {
-						return "", inputError(err.Error())
-					}
-
-					if extEsc[0] == 126 { // '~'
-						switch esc[1] {
-						//case 50: // Insert: "\x1b [ 2 ~"
-
-						case 51: // Delete: "\x1b [ 3 ~"
-							if err = ln.buf.deleteChar(); err != nil {
-								return "", err
-							}
-							continue
-							//case 53: // RePag: "\x1b [ 5 ~"
-
-							//case 54: // AvPag: "\x1b [ 6 ~"
-
-						}
-					}
-					if esc[1] == 49 && extEsc[0] == 59 && extEsc[1] == 53 { // "1;5"
-						switch extEsc[2] {
-						case 68: // Ctrl+left arrow: "\x1b [ 1 ; 5 D"
-							// move to last word
-							if err = ln.buf.wordBackward(); err != nil {
-								return "", err
-							}
-							continue
-						case 67: // Ctrl+right arrow: "\x1b [ 1 ; 5 C"
-							// move to next word
-							if err = ln.buf.wordForward(); err != nil {
-								return "", err
-							}
-							continue
-						}
-					}
-				}
-			}
-			continue
+	// Print the primary prompt.
+	if err = ln.Prompt(); err != nil {
+		return "", err
+	}
 
-		case sys.K_CTRL_T: // Swap actual character by the previous one.
-			if err = ln.buf.swap(); err != nil {
-				return "", err
-			}
-			continue
+	ln.resetUndo()
+	ln.line, ln.accepted = "", false
+	if ln.useHistory {
+		ln.histPos, ln.scratch = ln.hist.Len(), ""
+	}
+	ln.action, ln.last = 0, 0
 
-		case sys.K_CTRL_L: // Clear screen.
-			if _, err = term.Output.Write(DelScreenToUpper); err != nil {
-				return "", err
-			}
-			if err = ln.Prompt(); err != nil {
-				return "", err
-			}
-			continue
-		case sys.K_CTRL_U: // Delete the whole line.
-			if err = ln.buf.deleteLine(); err != nil {
-				return "", err
-			}
-			if err = ln.Prompt(); err != nil {
-				return "", err
-			}
-			continue
-		case sys.K_CTRL_K: // Delete from current to end of line.
-			if err = ln.buf.deleteToRight(); err != nil {
-				return "", err
-			}
-			continue
+	ln.reading = true
+	ln.ctx = ctx
+	defer func() {
+		ln.reading = false
+		ln.ctx = nil
 
-		case sys.K_CTRL_P: // Up
-			if !ln.useHistory {
-				continue
-			}
-			action = _UP
-		case sys.K_CTRL_N: // Down
-			if !ln.useHistory {
-				continue
-			}
-			action = _DOWN
-		case sys.K_CTRL_B: // Left
-			action = _LEFT
-		case sys.K_CTRL_F: // Right
-			action = _RIGHT
-
-		case sys.K_CTRL_A: // Start of line.
-			action = _HOME
-		case sys.K_CTRL_E: // End of line.
-			action = _END
+		if err == ErrIdleTimeout || (err != nil && err == ctx.Err()) {
+			ln.leave() // The error is not returned, to keep the one of the context.
 		}
+		ln.resized = false
+		if err2 := ln.writePending(); err == nil {
+			err = err2
+		}
+	}()
 
-		switch action {
-		case _UP, _DOWN: // Up and down arrow: history
-			if action == _UP {
-				anotherLine, err = ln.hist.Prev()
-			} else {
-				anotherLine, err = ln.hist.Next()
-			}
-			if err != nil {
-				continue
-			}
-
-			// Update the current history entry before to overwrite it with
-			// the next one.
-			// TODO: it has to be removed before of to be saved the history
-			if !isHistoryUsed {
-				ln.hist.Add(ln.buf.toString())
-			}
-			isHistoryUsed = true
-
-			ln.buf.grow(len(anotherLine))
-			ln.buf.size = len(anotherLine) + ln.buf.promptLen
-			copy(ln.buf.data[ln.lenPS1:], anotherLine)
+	for ; ; ln.last, ln.action = ln.action, 0 {
+		ln.recordEdit(ln.last)
 
-			if err = ln.buf.refresh(); err != nil {
-				return "", err
-			}
-			continue
-		case _LEFT:
-			if _, err = ln.buf.backward(); err != nil {
-				return "", err
-			}
-			continue
-		case _RIGHT:
-			if _, err = ln.buf.forward(); err != nil {
+		cmd, key, err := ln.keymaps[ln.mode].readCommand(ln)
+		if err != nil {
+			return "", err
+		}
+		if cmd == nil { // Key sequence not bound.
+			if err = ln.bell(); err != nil {
 				return "", err
 			}
 			continue
-		case _HOME:
-			if err = ln.buf.start(); err != nil {
-				return "", err
+		}
+
+		if err = ln.runCommand(cmd, key); err != nil {
+			if err == ErrInterrupt {
+				return ln.buf.toString(), err
 			}
-			continue
-		case _END:
-			if _, err = ln.buf.end(); err != nil {
-				return "", err
+			if ln.accepted { // Failed adding it to the history.
+				return strings.TrimSpace(ln.line), err
 			}
-			continue
+			return "", err
+		}
+		if ln.accepted {
+			return strings.TrimSpace(ln.line), nil
 		}
 	}
 }
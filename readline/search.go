@@ -0,0 +1,176 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tredoe/term/keys"
+)
+
+// searchState represents a state of the incremental search in the history.
+type searchState struct {
+	query    string
+	backward bool
+	failed   bool
+
+	entry int // Position of the entry matched; -1 if there is not
+	pos   int // Position of the query into the entry
+}
+
+// prompt returns the prompt shown for the state.
+func (st *searchState) prompt() []rune {
+	p := "("
+	if st.failed {
+		p += "failed "
+	}
+	if st.backward {
+		p += "reverse-"
+	}
+	return []rune(p + "i-search)`" + st.query + "': ")
+}
+
+// search runs an incremental search in the history, backward or forward, until
+// a match is accepted or the search is aborted (Ctrl+g).
+//
+// The search is finished at pressing any key which is not used to edit the
+// query, which is unread to be processed by Read, but Escape.
+func (ln *Line) search(backward bool) (err error) {
+	prompt := ln.buf.prompt()
+	text := []rune(ln.buf.toString())
+	textPos := ln.buf.pos - ln.buf.promptLen
+
+	// The states are stacked to come back at deleting characters of the query.
+	states := []searchState{{backward: backward, entry: -1}}
+	st := &states[0]
+
+	for {
+		// Show the match, or the original text whether there is not.
+		var match []rune
+		pos := textPos
+		if st.entry != -1 {
+			match = []rune(ln.hist.At(st.entry).Line)
+			pos = st.pos
+		} else {
+			match = text
+		}
+		if err = ln.buf.set(st.prompt(), match, pos); err != nil {
+			return err
+		}
+		if st.failed {
+			if err = ln.bell(); err != nil {
+				return err
+			}
+		}
+
+		key, err := ln.readKey()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case ctrl('r'), ctrl('s'):
+			next := *st
+			next.backward = key == ctrl('r')
+			if next.query == "" {
+				next.query = ln.lastSearch
+			}
+			next.entry, next.pos = ln.findEntry(next.query, st.entry, next.backward, next.query == st.query)
+			next.failed = next.entry == -1
+			if next.failed {
+				next.entry, next.pos = st.entry, st.pos
+			}
+			states = append(states, next)
+
+		case keys.Key{Code: keys.Backspace}, ctrl('h'):
+			if len(states) > 1 {
+				states = states[:len(states)-1]
+			}
+
+		case ctrl('g'): // Abort
+			return ln.buf.set(prompt, text, textPos)
+
+		default:
+			if !key.IsRune() {
+				if key != (keys.Key{Code: keys.Escape}) {
+					ln.in.UnreadKey(key)
+				}
+				if st.entry == -1 {
+					return ln.buf.set(prompt, text, textPos)
+				}
+				ln.lastSearch = st.query
+				ln.setHistoryPos(st.entry, string(text))
+				return ln.buf.set(prompt, match, pos)
+			}
+
+			next := *st
+			next.query += string(key.Rune)
+			if !next.failed {
+				next.entry, next.pos = ln.findEntry(next.query, st.entry, next.backward, false)
+				next.failed = next.entry == -1
+				if next.failed {
+					next.entry, next.pos = st.entry, st.pos
+				}
+			}
+			states = append(states, next)
+		}
+		st = &states[len(states)-1]
+	}
+}
+
+// findEntry finds the query in the entries of the history, starting from the
+// entry at the position from, or from the actual one if it is -1. If next is
+// true, the search starts from the next entry with a text different to the
+// first one.
+// It returns the position of the entry found, or -1, and the position of the
+// query into it.
+func (ln *Line) findEntry(query string, from int, backward, next bool) (int, int) {
+	if query == "" {
+		return -1, 0
+	}
+
+	if from == -1 {
+		// From the actual entry, or from the last one at the line being edited.
+		if from = ln.histPos; from == ln.hist.Len() {
+			if !backward {
+				return -1, 0
+			}
+			from--
+		}
+		next = false
+	}
+	if from < 0 {
+		return -1, 0
+	}
+	skip := ""
+	if next {
+		skip = ln.hist.At(from).Line
+	}
+
+	step := 1
+	if backward {
+		step = -1
+	}
+	for e := from; 0 <= e && e < ln.hist.Len(); e += step {
+		line := ln.hist.At(e).Line
+		if next && line == skip {
+			continue
+		}
+
+		var i int
+		if backward {
+			i = strings.LastIndex(line, query)
+		} else {
+			i = strings.Index(line, query)
+		}
+		if i != -1 {
+			return e, utf8.RuneCountInString(line[:i])
+		}
+	}
+	return -1, 0
+}
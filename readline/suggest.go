@@ -0,0 +1,99 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SuggestionStyle is the style of the suggestions, like the parameters of the
+// sequence SGR; dim by default.
+var SuggestionStyle = "2"
+
+// A Suggester returns the suggestion to complete the text of a line.
+type Suggester interface {
+	// Suggest returns the line suggested for the text, which has to start with
+	// the text to be shown, or an empty string.
+	Suggest(text string) string
+}
+
+// The SuggesterFunc type is an adapter to allow the use of ordinary functions
+// as suggesters.
+type SuggesterFunc func(text string) string
+
+// Suggest calls f(text).
+func (f SuggesterFunc) Suggest(text string) string {
+	return f(text)
+}
+
+// HistorySuggester returns a suggester of the last entry of the history which
+// starts with the text.
+func HistorySuggester(hist History) Suggester {
+	return SuggesterFunc(func(text string) string {
+		if text == "" || !hasHistory(hist) {
+			return ""
+		}
+		pos := findLast(hist, func(line string) bool {
+			return len(line) > len(text) && strings.HasPrefix(line, text)
+		})
+		if pos == -1 {
+			return ""
+		}
+		return hist.At(pos).Line
+	})
+}
+
+// SetSuggester sets the suggester used while the text is written; the rest of
+// the line suggested is shown after of the cursor, when it is at the end, and
+// it is accepted with Right or End, or word by word with Alt+f.
+// If s is nil then the suggestions are disabled.
+func (ln *Line) SetSuggester(s Suggester) {
+	ln.buf.suggester = s
+}
+
+// suggest sets the suggestion for the text when the cursor is at the end,
+// copying it after of the text, so its position can be got by pos2xy.
+func (b *buffer) suggest() {
+	b.ghost = nil
+	if b.suggester == nil || b.pos != b.size || b.size == b.promptLen {
+		return
+	}
+
+	text := b.toString()
+	line := b.suggester.Suggest(text)
+	if len(line) <= len(text) || !strings.HasPrefix(line, text) {
+		return
+	}
+
+	b.ghost = []rune(line[len(text):])
+	b.grow(b.size + len(b.ghost))
+	copy(b.data[b.size:], b.ghost)
+}
+
+// acceptSuggestion inserts the suggestion shown, or its next word.
+// Returns a boolean to know if there was a suggestion.
+func (b *buffer) acceptSuggestion(word bool) (bool, error) {
+	if len(b.ghost) == 0 || b.pos != b.size {
+		return false, nil
+	}
+
+	n := len(b.ghost)
+	if word {
+		i := 0
+		for i < n && unicode.IsSpace(b.ghost[i]) {
+			i++
+		}
+		for i < n && !unicode.IsSpace(b.ghost[i]) {
+			i++
+		}
+		n = i
+	}
+
+	end := b.size - b.promptLen
+	return true, b.replace(end, end, append([]rune(nil), b.ghost[:n]...))
+}
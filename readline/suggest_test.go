@@ -0,0 +1,63 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	hist, err := NewHistoryOfStore(NewMemoryStore(), HistoryCap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"git status", "git commit -m x", "ls"} {
+		hist.Add(s)
+	}
+
+	b := newBuffer(ioutil.Discard, 8)
+	b.reset([]rune("$ "))
+	b.suggester = HistorySuggester(hist)
+	b.insertRunes([]rune("git s"))
+
+	want := "$ git s\033[2mtatus\033[0m"
+	if got := string(b.toBytes()); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	// The suggestion is wrapped, but the cursor is kept.
+	if line, column := b.pos2xy(b.size + len(b.ghost)); line != 1 || column != 4 {
+		t.Errorf("expected (1, 4), got (%d, %d)", line, column)
+	}
+	if line, column := b.pos2xy(b.pos); line != 0 || column != 7 {
+		t.Errorf("expected (0, 7), got (%d, %d)", line, column)
+	}
+
+	b.deleteCharPrev()
+	b.insertRune('c')
+	if got := string(b.ghost); got != "ommit -m x" {
+		t.Errorf("expected suggestion %q, got %q", "ommit -m x", got)
+	}
+	b.acceptSuggestion(true)
+	b.acceptSuggestion(true)
+	if got := b.toString(); got != "git commit -m" {
+		t.Errorf("expected words accepted, got %q", got)
+	}
+	b.acceptSuggestion(false)
+	if got := b.toString(); got != "git commit -m x" || len(b.ghost) != 0 {
+		t.Errorf("expected suggestion accepted, got %q", got)
+	}
+
+	// It is not shown when the cursor is not at the end.
+	b.reset([]rune("$ "))
+	b.insertRunes([]rune("lt"))
+	b.backward()
+	b.insertRune('s')
+	if len(b.ghost) != 0 {
+		t.Errorf("expected no suggestion, got %q", string(b.ghost))
+	}
+}
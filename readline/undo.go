@@ -0,0 +1,94 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+// An edit represents a state of the line, to be restored at undoing.
+type edit struct {
+	text []rune
+	pos  int // Cursor position, relative to the prompt
+}
+
+// An undoList represents the states of the line before of each change.
+type undoList struct {
+	undo, redo []edit
+
+	last     edit      // State after of the last command
+	lastKind keyAction // Action of the last command
+}
+
+// snapshot returns the actual state of the line.
+func (ln *Line) snapshot() edit {
+	return edit{
+		text: ln.buf.text(0, ln.buf.size-ln.buf.promptLen),
+		pos:  ln.buf.pos - ln.buf.promptLen,
+	}
+}
+
+// resetUndo discards the changes recorded.
+func (ln *Line) resetUndo() {
+	ln.undos = undoList{last: ln.snapshot()}
+}
+
+// recordEdit records the state before of the last command, whether it changed
+// the text. The characters inserted in a row are grouped into a single change.
+func (ln *Line) recordEdit(kind keyAction) {
+	u := &ln.undos
+	cur := ln.snapshot()
+
+	if kind != _UNDO && !equalRunes(cur.text, u.last.text) {
+		if kind != _INSERT || u.lastKind != _INSERT {
+			u.undo = append(u.undo, u.last)
+		}
+		u.redo = u.redo[:0]
+	}
+	u.last = cur
+	u.lastKind = kind
+}
+
+// undo restores the state before of the last change.
+func (ln *Line) undo() error {
+	u := &ln.undos
+	if len(u.undo) == 0 {
+		return ln.bell()
+	}
+
+	prev := u.undo[len(u.undo)-1]
+	u.undo = u.undo[:len(u.undo)-1]
+	u.redo = append(u.redo, ln.snapshot())
+	return ln.restoreEdit(prev)
+}
+
+// redo restores the state undone the last time.
+func (ln *Line) redo() error {
+	u := &ln.undos
+	if len(u.redo) == 0 {
+		return ln.bell()
+	}
+
+	next := u.redo[len(u.redo)-1]
+	u.redo = u.redo[:len(u.redo)-1]
+	u.undo = append(u.undo, ln.snapshot())
+	return ln.restoreEdit(next)
+}
+
+// restoreEdit sets the line to the state e.
+func (ln *Line) restoreEdit(e edit) error {
+	return ln.buf.set(ln.buf.prompt(), e.text, e.pos)
+}
+
+// equalRunes reports whether a and b are equal.
+func equalRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
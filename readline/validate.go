@@ -0,0 +1,66 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+// A Validator reports whether the text of a line can be accepted.
+type Validator interface {
+	// IsComplete reports whether the text is complete. Else, a new line is
+	// inserted at pressing Enter, which is shown after of the continuation
+	// prompt.
+	IsComplete(text string) bool
+}
+
+// The ValidatorFunc type is an adapter to allow the use of ordinary functions
+// as validators.
+type ValidatorFunc func(text string) bool
+
+// IsComplete calls f(text).
+func (f ValidatorFunc) IsComplete(text string) bool {
+	return f(text)
+}
+
+// SetValidator sets the validator used at pressing Enter, so the text can be
+// written in several lines.
+// If v is nil then the text is always accepted.
+func (ln *Line) SetValidator(v Validator) {
+	ln.validator = v
+}
+
+// IsBalanced reports whether the text has not quotes nor brackets without
+// closing, and it does not finish with a backslash, like a command of a shell.
+// It can be used like validator through ValidatorFunc(IsBalanced).
+func IsBalanced(text string) bool {
+	var brackets []rune // Brackets opened
+	var quote rune      // Quote opened
+	escaped := false
+
+	for _, r := range text {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'', r == '"', r == '`':
+			quote = r
+		case r == '(', r == '[', r == '{':
+			brackets = append(brackets, r)
+		case r == ')', r == ']', r == '}':
+			// A bracket closed without opening is not completed by more text.
+			if n := len(brackets); n != 0 && closing[brackets[n-1]] == r {
+				brackets = brackets[:n-1]
+			}
+		}
+	}
+	return !escaped && quote == 0 && len(brackets) == 0
+}
+
+// closing are the closing brackets for each opening one.
+var closing = map[rune]rune{'(': ')', '[': ']', '{': '}'}
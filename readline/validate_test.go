@@ -0,0 +1,35 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import "testing"
+
+func TestIsBalanced(t *testing.T) {
+	tests := []struct {
+		in       string
+		balanced bool
+	}{
+		{"", true},
+		{"echo hello", true},
+		{"echo 'hello", false},
+		{"echo 'hello\nworld'", true},
+		{`echo "a\"b`, false},
+		{`echo 'a\'`, true},
+		{`echo \'a`, true},
+		{"echo a \\", false},
+		{"echo a \\\nb", true},
+		{"f(a, [b", false},
+		{"f(a, [b])", true},
+		{"{ \"}\"", false},
+		{"a)", true},
+	}
+	for _, tt := range tests {
+		if b := IsBalanced(tt.in); b != tt.balanced {
+			t.Errorf("%q: expected %v, got %v", tt.in, tt.balanced, b)
+		}
+	}
+}
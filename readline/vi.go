@@ -0,0 +1,645 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/tredoe/term/keys"
+)
+
+// A viCmd represents a command of the vi command mode, like "3dw" or "fx".
+type viCmd struct {
+	count int  // Number of times to repeat the command; 0 if it is not given
+	op    rune // Operator (d, c, y) applied to the motion; 0 if there is not
+	cmd   rune // Command, or motion of the operator
+	arg   rune // Character argument of f, F, t, T and r
+
+	text []rune // Text inserted after of the command, to be repeated by "."
+}
+
+// viState represents the state of the vi mode.
+type viState struct {
+	last    *viCmd // Last change, to be repeated
+	pending *viCmd // Change whose text is being inserted
+
+	insertStart int // Position where the insertion started
+
+	find       viCmd  // Last character search, to be repeated by ";" and ","
+	lastSearch string // Last query in the history search
+	backward   bool   // Direction of the last history search
+}
+
+// viAliases are the commands which are shortcuts of an operator and a motion.
+var viAliases = map[rune][2]rune{
+	'x': {'d', 'l'},
+	'X': {'d', 'h'},
+	's': {'c', 'l'},
+	'S': {'c', 'c'},
+	'D': {'d', '$'},
+	'C': {'c', '$'},
+	'Y': {'y', 'y'},
+}
+
+const (
+	viMotions  = " hlwWbBeE0^$|fFtT;,"
+	viCommands = "pPr~iaIAu.kj-+/?nN"
+)
+
+// readViRune reads a key, returning the character used in the vi command
+// mode; 0 if it is not valid.
+func (ln *Line) readViRune() (rune, error) {
+	key, err := ln.readKey()
+	if err != nil {
+		return 0, err
+	}
+	return viRune(key), nil
+}
+
+// viRune returns the character used in the vi command mode for the key.
+func viRune(key keys.Key) rune {
+	if key.IsRune() {
+		return key.Rune
+	}
+	if key == (keys.Key{Code: keys.Backspace}) {
+		return 'h'
+	}
+	return 0
+}
+
+// readViCount reads the count, if any, starting by the character r. It returns
+// the count and the next character.
+func (ln *Line) readViCount(r rune) (count int, next rune, err error) {
+	for ('1' <= r && r <= '9') || (count != 0 && r == '0') {
+		count = count*10 + int(r-'0')
+		if r, err = ln.readViRune(); err != nil {
+			return 0, 0, err
+		}
+	}
+	return count, r, nil
+}
+
+// readViCmd reads a command of the vi command mode, starting by the character
+// r. The command is nil whether it is not valid.
+func (ln *Line) readViCmd(r rune) (*viCmd, error) {
+	c := new(viCmd)
+	var err error
+
+	if c.count, r, err = ln.readViCount(r); err != nil {
+		return nil, err
+	}
+	if alias, ok := viAliases[r]; ok {
+		c.op, c.cmd = alias[0], alias[1]
+		return c, nil
+	}
+
+	if strings.ContainsRune("dcy", r) {
+		c.op = r
+		count := 0
+		if r, err = ln.readViRune(); err != nil {
+			return nil, err
+		}
+		if count, r, err = ln.readViCount(r); err != nil {
+			return nil, err
+		}
+		if count != 0 {
+			if c.count == 0 {
+				c.count = 1
+			}
+			c.count *= count
+		}
+		if r != c.op && (r == 0 || !strings.ContainsRune(viMotions, r)) {
+			return nil, nil
+		}
+	} else if r == 0 || !strings.ContainsRune(viMotions+viCommands, r) {
+		return nil, nil
+	}
+	c.cmd = r
+
+	if strings.ContainsRune("fFtTr", r) {
+		if c.arg, err = ln.readViRune(); err != nil {
+			return nil, err
+		}
+		if c.arg == 0 {
+			return nil, nil
+		}
+	}
+	return c, nil
+}
+
+// isChange reports whether the command changes the text, so it can be
+// repeated by ".".
+func (c *viCmd) isChange() bool {
+	return (c.op != 0 && c.op != 'y') || strings.ContainsRune("pPr~iaIA", c.cmd)
+}
+
+// == Commands
+//
+
+// viCommand runs a command of the vi command mode, being key the first one.
+func viCommand(ln *Line, key keys.Key) error {
+	c, err := ln.readViCmd(viRune(key))
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return ln.bell()
+	}
+
+	if c.cmd == '.' {
+		if ln.vi.last == nil {
+			return ln.bell()
+		}
+		last := *ln.vi.last
+		if c.count != 0 {
+			last.count = c.count
+			ln.vi.last.count = c.count
+		}
+		err = ln.viRun(&last, true)
+	} else {
+		if c.isChange() {
+			ln.vi.last = c
+		}
+		err = ln.viRun(c, false)
+	}
+	if err != nil {
+		return err
+	}
+	return ln.viClamp()
+}
+
+// viMovementMode changes to the command mode.
+func viMovementMode(ln *Line, _ keys.Key) error {
+	if c := ln.vi.pending; c != nil {
+		ln.vi.pending = nil
+
+		if pos := ln.Cursor(); pos >= ln.vi.insertStart {
+			c.text = ln.buf.text(ln.vi.insertStart, pos)
+		}
+		if c.op == 0 && c.count > 1 && len(c.text) != 0 {
+			ln.action = _INSERT
+			for i := 1; i < c.count; i++ {
+				if err := ln.Insert(string(c.text)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := ln.SetMode(ViCommandMode); err != nil {
+		return err
+	}
+	if ln.Cursor() != 0 {
+		_, err := ln.buf.backward()
+		return err
+	}
+	return nil
+}
+
+// viEditingMode changes to the vi insert mode.
+func viEditingMode(ln *Line, _ keys.Key) error {
+	return ln.SetMode(ViInsertMode)
+}
+
+// emacsEditingMode changes to the emacs mode.
+func emacsEditingMode(ln *Line, _ keys.Key) error {
+	return ln.SetMode(EmacsMode)
+}
+
+// viClamp moves the cursor to the last character whether it is after of the
+// text, since it is not allowed in the command mode.
+func (ln *Line) viClamp() error {
+	text := ln.buf.text(0, ln.buf.size-ln.buf.promptLen)
+	if ln.mode == ViCommandMode && len(text) != 0 && ln.Cursor() >= len(text) {
+		return ln.buf.moveTo(prevGrapheme(text, len(text)))
+	}
+	return nil
+}
+
+// viInsert changes to the insert mode at the position pos, to insert the text
+// of the change c. If repeat is true, the text inserted the last time is
+// inserted again, without changing of mode.
+func (ln *Line) viInsert(c *viCmd, pos int, repeat bool) error {
+	if err := ln.buf.moveTo(pos); err != nil {
+		return err
+	}
+	if !repeat {
+		ln.vi.pending = c
+		ln.vi.insertStart = pos
+		return ln.SetMode(ViInsertMode)
+	}
+
+	count := 1
+	if c.op == 0 && c.count > 1 {
+		count = c.count
+	}
+	if len(c.text) == 0 {
+		return nil
+	}
+	if err := ln.Insert(strings.Repeat(string(c.text), count)); err != nil {
+		return err
+	}
+	_, err := ln.buf.backward()
+	return err
+}
+
+// viRun runs the command c. If repeat is true, the command is being repeated
+// by ".".
+func (ln *Line) viRun(c *viCmd, repeat bool) error {
+	text := ln.buf.text(0, ln.buf.size-ln.buf.promptLen)
+	pos := ln.Cursor()
+	count := c.count
+	if count == 0 {
+		count = 1
+	}
+
+	if c.op != 0 {
+		start, end := 0, len(text)
+		if c.cmd != c.op {
+			to, inclusive, ok := ln.viMotion(text, pos, c, count)
+			if !ok {
+				return ln.bell()
+			}
+			start, end = pos, to
+			if to < pos {
+				start, end = to, pos
+			}
+			if inclusive && end < len(text) {
+				end++
+			}
+		}
+		return ln.viOperate(c, start, end, repeat)
+	}
+
+	switch c.cmd {
+	case 'i':
+		return ln.viInsert(c, pos, repeat)
+	case 'a':
+		if pos < len(text) {
+			pos++
+		}
+		return ln.viInsert(c, pos, repeat)
+	case 'I':
+		return ln.viInsert(c, viFirstNonBlank(text), repeat)
+	case 'A':
+		return ln.viInsert(c, len(text), repeat)
+
+	case 'p', 'P':
+		paste := ln.killRing.yank()
+		if paste == nil {
+			return ln.bell()
+		}
+		if c.cmd == 'p' && pos < len(text) {
+			pos++
+		}
+		paste = []rune(strings.Repeat(string(paste), count))
+		if err := ln.buf.replace(pos, pos, paste); err != nil {
+			return err
+		}
+		return ln.buf.moveTo(pos + len(paste) - 1)
+
+	case 'r':
+		if pos+count > len(text) {
+			return ln.bell()
+		}
+		if err := ln.buf.replace(pos, pos+count, []rune(strings.Repeat(string(c.arg), count))); err != nil {
+			return err
+		}
+		return ln.buf.moveTo(pos + count - 1)
+
+	case '~':
+		if pos == len(text) {
+			return ln.bell()
+		}
+		end := pos + count
+		if end > len(text) {
+			end = len(text)
+		}
+		toggled := make([]rune, 0, end-pos)
+		for _, r := range text[pos:end] {
+			if unicode.IsUpper(r) {
+				r = unicode.ToLower(r)
+			} else {
+				r = unicode.ToUpper(r)
+			}
+			toggled = append(toggled, r)
+		}
+		return ln.buf.replace(pos, end, toggled)
+
+	case 'u':
+		ln.action = _UNDO
+		return ln.undo()
+
+	case 'k', '-', 'j', '+':
+		up := c.cmd == 'k' || c.cmd == '-'
+		if moved, err := ln.buf.moveLine(up); moved || err != nil {
+			return err
+		}
+		if !ln.useHistory {
+			return ln.bell()
+		}
+		for i := 0; i < count; i++ {
+			if err := historyLine(ln, up); err != nil {
+				return err
+			}
+		}
+		return ln.buf.moveTo(0)
+
+	case '/', '?':
+		return ln.viSearch(c.cmd == '/', false)
+	case 'n', 'N':
+		return ln.viSearch(ln.vi.backward == (c.cmd == 'n'), true)
+	}
+
+	to, _, ok := ln.viMotion(text, pos, c, count)
+	if !ok {
+		return ln.bell()
+	}
+	return ln.buf.moveTo(to)
+}
+
+// viOperate applies the operator of the command c to the text between the
+// positions start and end.
+func (ln *Line) viOperate(c *viCmd, start, end int, repeat bool) error {
+	if start == end && c.op != 'c' {
+		return ln.bell()
+	}
+	ln.killRing.add(ln.buf.text(start, end), false, false)
+
+	switch c.op {
+	case 'y':
+		return ln.buf.moveTo(start)
+	case 'd':
+		return ln.buf.replace(start, end, nil)
+	}
+
+	// Change
+	if !repeat {
+		ln.action = _INSERT
+	}
+	if err := ln.buf.replace(start, end, nil); err != nil {
+		return err
+	}
+	return ln.viInsert(c, start, repeat)
+}
+
+// viSearch searches in the history, reading the query, or using the last one
+// if next is true.
+func (ln *Line) viSearch(backward, next bool) error {
+	if !ln.useHistory {
+		return ln.bell()
+	}
+
+	query := ln.vi.lastSearch
+	if !next {
+		q, ok, err := ln.viReadQuery(backward)
+		if err != nil || !ok {
+			return err
+		}
+		if q != "" {
+			query = q
+		}
+		ln.vi.lastSearch, ln.vi.backward = query, backward
+	}
+
+	from := -1
+	if next && ln.histPos < ln.hist.Len() {
+		from = ln.histPos
+	}
+	e, _ := ln.findEntry(query, from, backward, next)
+	if e == -1 {
+		return ln.bell()
+	}
+	ln.setHistoryPos(e, ln.buf.toString())
+	return ln.buf.set(ln.buf.prompt(), []rune(ln.hist.At(e).Line), 0)
+}
+
+// viReadQuery reads the query of a search in the history, showing "/" or "?"
+// like prompt. It reports false if the search is cancelled.
+func (ln *Line) viReadQuery(backward bool) (query string, ok bool, err error) {
+	prompt := ln.buf.prompt()
+	text := ln.buf.text(0, ln.buf.size-ln.buf.promptLen)
+	pos := ln.Cursor()
+
+	searchPrompt := []rune("?")
+	if backward {
+		searchPrompt = []rune("/")
+	}
+
+	var q []rune
+	for {
+		if err = ln.buf.set(searchPrompt, q, len(q)); err != nil {
+			return "", false, err
+		}
+
+		key, err := ln.readKey()
+		if err != nil {
+			return "", false, err
+		}
+
+		switch {
+		case key == keys.Key{Code: keys.Enter}, key == ctrl('j'):
+			return string(q), true, ln.buf.set(prompt, text, pos)
+
+		case key == keys.Key{Code: keys.Backspace}, key == ctrl('h'):
+			if len(q) != 0 {
+				q = q[:len(q)-1]
+				continue
+			}
+			fallthrough
+		case key == keys.Key{Code: keys.Escape}, key == ctrl('c'), key == ctrl('g'):
+			return "", false, ln.buf.set(prompt, text, pos)
+
+		case key.IsRune():
+			q = append(q, key.Rune)
+		default:
+			if err = ln.bell(); err != nil {
+				return "", false, err
+			}
+		}
+	}
+}
+
+// == Motions
+//
+
+// viMotion returns the position, relative to the prompt, where the motion of
+// the command c moves the cursor from pos, and whether the character at that
+// position is included by an operator. It reports false if the motion fails.
+func (ln *Line) viMotion(text []rune, pos int, c *viCmd, count int) (to int, inclusive, ok bool) {
+	n := len(text)
+	to = pos
+
+	switch c.cmd {
+	case 'h':
+		for i := 0; i < count && to > 0; i++ {
+			to = prevGrapheme(text, to)
+		}
+	case 'l', ' ':
+		for i := 0; i < count && to < n; i++ {
+			to += graphemeLen(text, to)
+		}
+
+	case 'w', 'W':
+		big := c.cmd == 'W'
+		// "cw" changes until the end of the word, like "ce".
+		if c.op == 'c' && pos < n && !unicode.IsSpace(text[pos]) {
+			for i := 0; i < count; i++ {
+				to = viWordEnd(text, to, big, i == 0)
+			}
+			return to, true, true
+		}
+		for i := 0; i < count; i++ {
+			to = viWordForward(text, to, big)
+		}
+	case 'b', 'B':
+		for i := 0; i < count; i++ {
+			to = viWordBackward(text, to, c.cmd == 'B')
+		}
+	case 'e', 'E':
+		for i := 0; i < count; i++ {
+			to = viWordEnd(text, to, c.cmd == 'E', false)
+		}
+		if to < 0 { // Without text
+			return pos, false, false
+		}
+		return to, true, true
+
+	case '0':
+		to = 0
+	case '^':
+		to = viFirstNonBlank(text)
+	case '$':
+		to = n
+	case '|':
+		if to = count - 1; to > n {
+			to = n
+		}
+
+	case 'f', 'F', 't', 'T':
+		ln.vi.find = viCmd{cmd: c.cmd, arg: c.arg}
+		return viFind(text, pos, c.cmd, c.arg, count)
+	case ';', ',':
+		find := ln.vi.find
+		if find.cmd == 0 {
+			return pos, false, false
+		}
+		if c.cmd == ',' {
+			find.cmd = map[rune]rune{'f': 'F', 'F': 'f', 't': 'T', 'T': 't'}[find.cmd]
+		}
+		return viFind(text, pos, find.cmd, find.arg, count)
+	}
+	return to, false, true
+}
+
+// viFind returns the position of the count-th occurrence of the character r,
+// forward (f, t) or backward (F, T) from pos. The commands t and T stop before
+// of the character.
+func viFind(text []rune, pos int, cmd, r rune, count int) (to int, inclusive, ok bool) {
+	i := pos
+	if cmd == 'f' || cmd == 't' {
+		for ; count > 0; count-- {
+			for i++; i < len(text) && text[i] != r; i++ {
+			}
+			if i >= len(text) {
+				return pos, false, false
+			}
+		}
+		if cmd == 't' {
+			i--
+		}
+		return i, true, true
+	}
+
+	for ; count > 0; count-- {
+		for i--; i >= 0 && text[i] != r; i-- {
+		}
+		if i < 0 {
+			return pos, false, false
+		}
+	}
+	if cmd == 'T' {
+		i++
+	}
+	return i, false, true
+}
+
+// viClass returns the class of the character r into a word: 0 for spaces, 1
+// for letters, digits and "_", and 2 for the rest. If big is true, there are
+// only spaces and non-spaces.
+func viClass(r rune, big bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case big, r == '_', unicode.IsLetter(r), unicode.IsDigit(r):
+		return 1
+	}
+	return 2
+}
+
+// viWordForward returns the position of the start of the next word.
+func viWordForward(text []rune, pos int, big bool) int {
+	n := len(text)
+	if pos >= n {
+		return n
+	}
+	if c := viClass(text[pos], big); c != 0 {
+		for pos < n && viClass(text[pos], big) == c {
+			pos++
+		}
+	}
+	for pos < n && unicode.IsSpace(text[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// viWordBackward returns the position of the start of the previous word.
+func viWordBackward(text []rune, pos int, big bool) int {
+	for pos > 0 && unicode.IsSpace(text[pos-1]) {
+		pos--
+	}
+	if pos > 0 {
+		c := viClass(text[pos-1], big)
+		for pos > 0 && viClass(text[pos-1], big) == c {
+			pos--
+		}
+	}
+	return pos
+}
+
+// viWordEnd returns the position of the end of the word. If stay is true, the
+// end of the actual word is returned when pos is into it.
+func viWordEnd(text []rune, pos int, big, stay bool) int {
+	n := len(text)
+	if !stay {
+		pos++
+	}
+	for pos < n && unicode.IsSpace(text[pos]) {
+		pos++
+	}
+	if pos >= n {
+		return n - 1
+	}
+	c := viClass(text[pos], big)
+	for pos+1 < n && viClass(text[pos+1], big) == c {
+		pos++
+	}
+	return pos
+}
+
+// viFirstNonBlank returns the position of the first character which is not a
+// space.
+func viFirstNonBlank(text []rune) int {
+	for i, r := range text {
+		if !unicode.IsSpace(r) {
+			return i
+		}
+	}
+	return len(text)
+}
@@ -0,0 +1,230 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import "unicode"
+
+// Characters used to join others.
+const (
+	zeroWidthJoiner = 0x200D
+	variationEmoji  = 0xFE0F // Variation selector to show the emoji style
+)
+
+// wideRanges are the ranges of characters which fill two columns, like the
+// "Wide" and "Fullwidth" ones in the East Asian Width property of Unicode.
+var wideRanges = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115F, 1},
+		{0x231A, 0x231B, 1},
+		{0x2329, 0x232A, 1},
+		{0x23E9, 0x23EC, 1},
+		{0x23F0, 0x23F3, 3},
+		{0x25FD, 0x25FE, 1},
+		{0x2614, 0x2615, 1},
+		{0x2648, 0x2653, 1},
+		{0x267F, 0x2693, 20},
+		{0x26A1, 0x26AA, 9},
+		{0x26AB, 0x26BD, 18},
+		{0x26BE, 0x26C4, 6},
+		{0x26C5, 0x26CE, 9},
+		{0x26D4, 0x26EA, 22},
+		{0x26F2, 0x26F3, 1},
+		{0x26F5, 0x26FA, 5},
+		{0x26FD, 0x2705, 8},
+		{0x270A, 0x270B, 1},
+		{0x2728, 0x274C, 36},
+		{0x274E, 0x2753, 5},
+		{0x2754, 0x2755, 1},
+		{0x2757, 0x2795, 62},
+		{0x2796, 0x2797, 1},
+		{0x27B0, 0x27BF, 15},
+		{0x2B1B, 0x2B1C, 1},
+		{0x2B50, 0x2B55, 5},
+		{0x2E80, 0x303E, 1},
+		{0x3041, 0x33FF, 1},
+		{0x3400, 0x4DBF, 1},
+		{0x4E00, 0x9FFF, 1},
+		{0xA000, 0xA4CF, 1},
+		{0xA960, 0xA97F, 1},
+		{0xAC00, 0xD7A3, 1},
+		{0xF900, 0xFAFF, 1},
+		{0xFE10, 0xFE19, 1},
+		{0xFE30, 0xFE6F, 1},
+		{0xFF00, 0xFF60, 1},
+		{0xFFE0, 0xFFE6, 1},
+	},
+	R32: []unicode.Range32{
+		{0x16FE0, 0x16FE4, 1},
+		{0x17000, 0x18AFF, 1},
+		{0x1B000, 0x1B2FF, 1},
+		{0x1F004, 0x1F0CF, 203},
+		{0x1F18E, 0x1F191, 3},
+		{0x1F192, 0x1F19A, 1},
+		{0x1F200, 0x1F202, 1},
+		{0x1F210, 0x1F23B, 1},
+		{0x1F240, 0x1F248, 1},
+		{0x1F250, 0x1F251, 1},
+		{0x1F260, 0x1F265, 1},
+		{0x1F300, 0x1F64F, 1},
+		{0x1F680, 0x1F6FF, 1},
+		{0x1F7E0, 0x1F7EB, 1},
+		{0x1F90C, 0x1F9FF, 1},
+		{0x1FA70, 0x1FAFF, 1},
+		{0x20000, 0x2FFFD, 1},
+		{0x30000, 0x3FFFD, 1},
+	},
+}
+
+// runeWidth returns the number of columns used by the character r in the
+// terminal: 0 for the control and combining characters, 2 for the wide ones,
+// and 1 for the rest.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20, 0x7F <= r && r < 0xA0:
+		return 0
+	case r < 0x300: // Latin
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf),
+		0x1160 <= r && r <= 0x11FF: // Hangul vowels and final consonants
+		return 0
+	case unicode.Is(wideRanges, r):
+		return 2
+	}
+	return 1
+}
+
+// isExtender reports whether the character r is shown together with the
+// previous one, like the combining characters and the emoji modifiers.
+func isExtender(r rune) bool {
+	return runeWidth(r) == 0 && r >= 0x300 || 0x1F3FB <= r && r <= 0x1F3FF
+}
+
+// isRegionalIndicator reports whether the character r is a letter used in
+// pairs to show a flag.
+func isRegionalIndicator(r rune) bool {
+	return 0x1F1E6 <= r && r <= 0x1F1FF
+}
+
+// graphemeLen returns the number of characters of the grapheme cluster which
+// starts at the position i of text, that is, the characters shown like a
+// single one: a character with its combining marks, an emoji sequence joined
+// by ZWJ, or a flag.
+func graphemeLen(text []rune, i int) int {
+	if i >= len(text) {
+		return 0
+	}
+
+	n := 1
+	if isRegionalIndicator(text[i]) && i+1 < len(text) && isRegionalIndicator(text[i+1]) {
+		n = 2
+	}
+	for i+n < len(text) {
+		switch r := text[i+n]; {
+		case r == zeroWidthJoiner && i+n+1 < len(text):
+			n += 2
+		case isExtender(r):
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// prevGrapheme returns the position of the grapheme cluster before of the
+// position i of text.
+func prevGrapheme(text []rune, i int) int {
+	start := 0
+	for j := 0; j < i; j += graphemeLen(text[:i], j) {
+		start = j
+	}
+	return start
+}
+
+// graphemeWidth returns the number of columns used by the grapheme cluster g.
+func graphemeWidth(g []rune) int {
+	if len(g) == 0 {
+		return 0
+	}
+	if isRegionalIndicator(g[0]) && len(g) > 1 && isRegionalIndicator(g[1]) {
+		return 2
+	}
+	w := runeWidth(g[0])
+	for _, r := range g[1:] {
+		if r == variationEmoji {
+			return 2
+		}
+	}
+	return w
+}
+
+// stringWidth returns the number of columns used by s in the terminal.
+func stringWidth(s string) int {
+	text := []rune(s)
+	w := 0
+	for i := 0; i < len(text); {
+		n := graphemeLen(text, i)
+		w += graphemeWidth(text[i : i+n])
+		i += n
+	}
+	return w
+}
+
+// Markers of the start and end of characters which are not shown, like in the
+// prompts of GNU Readline.
+const (
+	ignoreStart = '\001'
+	ignoreEnd   = '\002'
+)
+
+// promptWidth returns the number of columns used by the prompt in the
+// terminal, skipping the escape sequences SGR, OSC and the rest of ANSI ones,
+// and the characters between the markers \001 and \002.
+func promptWidth(prompt []rune) int {
+	visible := make([]rune, 0, len(prompt))
+
+	for i := 0; i < len(prompt); i++ {
+		switch prompt[i] {
+		case ignoreStart:
+			for i++; i < len(prompt) && prompt[i] != ignoreEnd; i++ {
+			}
+		case '\033':
+			i = skipEscape(prompt, i)
+		default:
+			visible = append(visible, prompt[i])
+		}
+	}
+	return stringWidth(string(visible))
+}
+
+// skipEscape returns the position of the last character of the escape
+// sequence which starts at the position i of s.
+func skipEscape(s []rune, i int) int {
+	if i+1 >= len(s) {
+		return i
+	}
+
+	switch s[i+1] {
+	case '[': // CSI, finished by a character between '@' and '~'
+		for i += 2; i < len(s) && (s[i] < '@' || s[i] > '~'); i++ {
+		}
+	case ']': // OSC, finished by BEL or ST (ESC \\)
+		for i += 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				break
+			}
+			if s[i] == '\033' && i+1 < len(s) && s[i+1] == '\\' {
+				i++
+				break
+			}
+		}
+	default: // Intermediate characters, and the final one
+		for i++; i+1 < len(s) && ' ' <= s[i] && s[i] <= '/'; i++ {
+		}
+	}
+	return i
+}
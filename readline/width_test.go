@@ -0,0 +1,111 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package readline
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+	}{
+		{"abc", 3},
+		{"\u65e5\u672c\u8a9e", 6},
+		{"\uff46\uff55\uff4c\uff4c", 8}, // fullwidth
+		{"e\u0301", 1},                  // combining acute accent
+		{"\uD55C\uAD6D", 4},             // precomposed Hangul
+		{"\u1100\u1161", 2},             // conjoining Hangul
+		{"\U0001F44D", 2},
+		{"\U0001F44D\U0001F3FD", 2}, // emoji modifier
+		{"\U0001F469\u200D\U0001F469\u200D\U0001F467", 2}, // ZWJ sequence
+		{"\U0001F1EA\U0001F1F8", 2},                       // flag
+		{"\u2764\uFE0F", 2},                               // emoji presentation
+		{"a\u200Bb", 2},                                   // zero width space
+	}
+	for _, tt := range tests {
+		if w := stringWidth(tt.in); w != tt.width {
+			t.Errorf("%q: expected width %d, got %d", tt.in, tt.width, w)
+		}
+	}
+}
+
+func TestGrapheme(t *testing.T) {
+	text := []rune("ae\u0301\U0001F469\u200D\U0001F467\U0001F1EA\U0001F1F8x")
+	bounds := []int{0, 1, 3, 6, 8, 9}
+
+	for i := 0; i < len(bounds)-1; i++ {
+		if n := graphemeLen(text, bounds[i]); bounds[i]+n != bounds[i+1] {
+			t.Errorf("graphemeLen(%d): expected %d, got %d", bounds[i], bounds[i+1]-bounds[i], n)
+		}
+		if p := prevGrapheme(text, bounds[i+1]); p != bounds[i] {
+			t.Errorf("prevGrapheme(%d): expected %d, got %d", bounds[i+1], bounds[i], p)
+		}
+	}
+}
+
+func TestPos2xy(t *testing.T) {
+	b := newBuffer(ioutil.Discard, 5)
+	b.reset([]rune("$ "))
+	b.insertRunes([]rune("\u65e5\u672c\u8a9ee\u0301x"))
+
+	tests := []struct {
+		pos          int
+		line, column int
+	}{
+		{0, 0, 0},
+		{2, 0, 2},
+		{3, 0, 4},
+		{4, 1, 2}, // the wide character does not fit at the end of the line
+		{5, 1, 4},
+		{7, 2, 0}, // the line is filled
+		{8, 2, 1},
+	}
+	for _, tt := range tests {
+		if line, column := b.pos2xy(tt.pos); line != tt.line || column != tt.column {
+			t.Errorf("pos %d: expected (%d, %d), got (%d, %d)",
+				tt.pos, tt.line, tt.column, line, column)
+		}
+	}
+
+	// The new lines are placed after of the continuation prompt.
+	b = newBuffer(ioutil.Discard, 5)
+	b.reset([]rune("$ "))
+	b.setContPrompt([]rune("> "))
+	b.insertRunes([]rune("ab\ncdef"))
+	if line, column := b.pos2xy(b.size); line != 2 || column != 1 {
+		t.Errorf("expected (2, 1), got (%d, %d)", line, column)
+	}
+}
+
+func TestPromptWidth(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+	}{
+		{"$ ", 2},
+		{"\033[1;32muser\033[0m $ ", 7},
+		{"\033]0;title\a> ", 2},
+		{"\033]0;title\033\\> ", 2},
+		{"\001\033[34m\002日> \001\033[0m\002", 4},
+		{"\033(B> ", 2},
+	}
+	for _, tt := range tests {
+		if w := promptWidth([]rune(tt.in)); w != tt.width {
+			t.Errorf("%q: expected width %d, got %d", tt.in, tt.width, w)
+		}
+	}
+
+	b := newBuffer(ioutil.Discard, 5)
+	b.reset([]rune("\033[1;32m$\033[0m "))
+	b.insertRunes([]rune("abcd"))
+	if line, column := b.pos2xy(b.size); line != 1 || column != 1 {
+		t.Errorf("expected (1, 1), got (%d, %d)", line, column)
+	}
+}
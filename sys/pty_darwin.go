@@ -0,0 +1,48 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Reference: man ptsname ; man unlockpt ; /usr/include/sys/ttycom.h
+
+package sys
+
+import (
+	"bytes"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// PTMX is the multiplexor device used to open the master of a pseudo-terminal.
+const PTMX = "/dev/ptmx"
+
+// char *ptsname(int fd)
+
+// Ptsname returns the name of the slave pseudo-terminal device corresponding
+// to the master referred to by fd.
+func Ptsname(fd int) (name string, err error) {
+	var buf [128]byte // Size given by TIOCPTYGNAME
+
+	_, _, e1 := unix.Syscall(unix.SYS_IOCTL, uintptr(fd),
+		uintptr(unix.TIOCPTYGNAME), uintptr(unsafe.Pointer(&buf[0])))
+	if e1 != 0 {
+		return "", e1
+	}
+	if i := bytes.IndexByte(buf[:], 0); i != -1 {
+		return string(buf[:i]), nil
+	}
+	return string(buf[:]), nil
+}
+
+// int unlockpt(int fd)
+
+// Unlockpt grants the access to the slave pseudo-terminal device corresponding
+// to the master referred to by fd, and unlocks it.
+func Unlockpt(fd int) (err error) {
+	if err = unix.IoctlSetInt(fd, unix.TIOCPTYGRANT, 0); err != nil {
+		return err
+	}
+	return unix.IoctlSetInt(fd, unix.TIOCPTYUNLK, 0)
+}
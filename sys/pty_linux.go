@@ -0,0 +1,49 @@
+// Copyright 2013 Jonas mg
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Reference: man pts ; man ptsname ; man unlockpt
+
+package sys
+
+import (
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// PTMX is the multiplexor device used to open the master of a pseudo-terminal.
+const PTMX = "/dev/ptmx"
+
+// char *ptsname(int fd)
+
+// Ptsname returns the name of the slave pseudo-terminal device corresponding
+// to the master referred to by fd.
+func Ptsname(fd int) (name string, err error) {
+	var n uint32
+
+	_, _, e1 := unix.Syscall(unix.SYS_IOCTL, uintptr(fd),
+		uintptr(TIOCGPTN), uintptr(unsafe.Pointer(&n)))
+	if e1 != 0 {
+		return "", e1
+	}
+	return "/dev/pts/" + strconv.Itoa(int(n)), nil
+}
+
+// int unlockpt(int fd)
+
+// Unlockpt unlocks the slave pseudo-terminal device corresponding to the
+// master referred to by fd.
+func Unlockpt(fd int) (err error) {
+	var lock int32 // 0 to unlock
+
+	_, _, e1 := unix.Syscall(unix.SYS_IOCTL, uintptr(fd),
+		uintptr(TIOCSPTLCK), uintptr(unsafe.Pointer(&lock)))
+	if e1 != 0 {
+		err = e1
+	}
+	return
+}
@@ -9,3 +9,4 @@
 package sys
 
 //cgo const (TCGETS, TCSETS, TCSETSW, TCSETSF)
+//cgo const (TIOCGPTN, TIOCSPTLCK)
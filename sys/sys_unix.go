@@ -58,10 +58,20 @@ func GetWinsize(fd int, ws *Winsize) (err error) {
 	return
 }
 
+// SetWinsize sets the terminal size from the winsize struct.
+func SetWinsize(fd int, ws *Winsize) (err error) {
+	_, _, e1 := unix.Syscall(unix.SYS_IOCTL, uintptr(fd),
+		uintptr(TIOCSWINSZ), uintptr(unsafe.Pointer(ws)))
+	if e1 != 0 {
+		err = e1
+	}
+	return
+}
+
 // Types
 
 //cgo const (TCSANOW, TCSADRAIN, TCSAFLUSH)
-//cgo const TIOCGWINSZ
+//cgo const (TIOCGWINSZ, TIOCSWINSZ)
 
 //cgo type struct_termios
 //cgo type struct_winsize
@@ -12,7 +12,10 @@ const (
 	TCSANOW   = 0x0
 )
 
-const TIOCGWINSZ = 0x40087468
+const (
+	TIOCGWINSZ = 0x40087468
+	TIOCSWINSZ = 0x80087467
+)
 
 const (
 	VDISCARD = 0xf
@@ -12,7 +12,10 @@ const (
 	TCSANOW   = 0x0
 )
 
-const TIOCGWINSZ = 0x5413
+const (
+	TIOCGWINSZ = 0x5413
+	TIOCSWINSZ = 0x5414
+)
 
 const (
 	VDISCARD = 0xd
@@ -132,6 +135,11 @@ const (
 	TCSETSW = 0x5403
 )
 
+const (
+	TIOCGPTN   = 0x80045430
+	TIOCSPTLCK = 0x40045431
+)
+
 type Termios struct {
 	Iflag uint32
 	Oflag uint32
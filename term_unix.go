@@ -33,20 +33,44 @@ type Terminal struct {
 	// Window size
 	size sys.Winsize
 
-	fd int // File descriptor
+	fd     int // File descriptor
+	sizeFd int // File descriptor used to get the window size
+
+	in  io.Reader
+	out io.Writer
 }
 
-// New creates a new terminal interface in the file descriptor InputFD.
+// New creates a new terminal interface in the file descriptor InputFD, using
+// the input and output by default.
 func New() (*Terminal, error) {
+	return newTerminal(InputFD, unix.Stdout, Input, Output)
+}
+
+// NewFromFile creates a new terminal interface which reads from in and writes
+// to out. The file in has to be a terminal, and the window size is got from
+// out whether it is a terminal too.
+func NewFromFile(in, out *os.File) (*Terminal, error) {
+	sizeFd := int(out.Fd())
+	if !IsTerminal(sizeFd) {
+		sizeFd = int(in.Fd())
+	}
+	return newTerminal(int(in.Fd()), sizeFd, in, out)
+}
+
+// newTerminal is the base to create a terminal interface.
+func newTerminal(fd, sizeFd int, in io.Reader, out io.Writer) (*Terminal, error) {
 	var t Terminal
 
 	// Get the actual state
-	if err := sys.Getattr(InputFD, &t.lastState); err != nil {
+	if err := sys.Getattr(fd, &t.lastState); err != nil {
 		return nil, os.NewSyscallError("sys.Getattr", err)
 	}
 
 	t.oldState = t.lastState // the actual state is copied to another one
-	t.fd = InputFD
+	t.fd = fd
+	t.sizeFd = sizeFd
+	t.in = in
+	t.out = out
 	return &t, nil
 }
 
@@ -176,10 +200,32 @@ func (t *Terminal) Fd() int {
 	return t.fd
 }
 
+// Input returns the reader of the term.
+func (t *Terminal) Input() io.Reader {
+	return t.in
+}
+
+// Output returns the writer of the term.
+func (t *Terminal) Output() io.Writer {
+	return t.out
+}
+
 // GetSize returns the size of the term.
 func (t *Terminal) GetSize() (row, column int, err error) {
-	if err = sys.GetWinsize(unix.Stdout, &t.size); err != nil {
+	if err = sys.GetWinsize(t.sizeFd, &t.size); err != nil {
 		return
 	}
 	return int(t.size.Row), int(t.size.Col), nil
 }
+
+// SetSize sets the size of the terminal referred to by the file descriptor fd.
+// It is mainly used to set the size of a pseudo-terminal, which is 0x0 when it
+// is opened.
+func SetSize(fd, row, column int) error {
+	ws := sys.Winsize{Row: uint16(row), Col: uint16(column)}
+
+	if err := sys.SetWinsize(fd, &ws); err != nil {
+		return os.NewSyscallError("sys.SetWinsize", err)
+	}
+	return nil
+}
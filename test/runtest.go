@@ -286,8 +286,9 @@ func TestEditLine() {
 
 	for {
 		if _, err = ln.Read(); err != nil {
-			if err == readline.ErrCtrlD {
+			if err == io.EOF {
 				hist.Save()
+				hist.Close()
 				err = nil
 			} else {
 				log.Print(err)
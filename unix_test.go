@@ -11,6 +11,7 @@ package term
 import (
 	"syscall"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -84,3 +85,30 @@ func TestSize(t *testing.T) {
 		//t.Error("expected to get size from environment")
 	//}
 }
+
+func TestDetectWinSize(t *testing.T) {
+	w := DetectWinSize()
+
+	// Several signals are indicated like a single change.
+	for i := 0; i < 3; i++ {
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGWINCH); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case <-w.Change:
+	case <-time.After(time.Second):
+		t.Fatal("expected change of size")
+	}
+	select {
+	case <-w.Change:
+		t.Error("expected a single change")
+	default:
+	}
+
+	// It is closed without receiving the changes.
+	syscall.Kill(syscall.Getpid(), syscall.SIGWINCH)
+	time.Sleep(20 * time.Millisecond)
+	w.Close()
+}
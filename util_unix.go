@@ -148,27 +148,31 @@ type WinSize struct {
 	Change chan bool
 	quit   chan bool
 	wait   chan bool
+	sig    chan os.Signal
 }
 
 // DetectWinSize caughts a signal named SIGWINCH whenever the window size changes,
-// being indicated in channel `WinSize.Change`.
+// being indicated in channel `WinSize.Change`. The changes are not queued: the
+// ones happened while a change is not received yet are indicated only once.
 func DetectWinSize() *WinSize {
 	w := &WinSize{
+		make(chan bool, 1),
 		make(chan bool),
 		make(chan bool),
-		make(chan bool),
+		make(chan os.Signal, 1),
 	}
-
-	changeSig := make(chan os.Signal)
-	signal.Notify(changeSig, unix.SIGWINCH)
+	signal.Notify(w.sig, unix.SIGWINCH)
 
 	go func() {
 		for {
 			select {
-			case <-changeSig:
+			case <-w.sig:
 				// Add a pause because it is sent two signals at maximizing a window.
 				time.Sleep(7 * time.Millisecond)
-				w.Change <- true
+				select {
+				case w.Change <- true:
+				default: // A change is already pending.
+				}
 			case <-w.quit:
 				w.wait <- true
 				return
@@ -180,6 +184,7 @@ func DetectWinSize() *WinSize {
 
 // Close closes the goroutine started to trap the signal.
 func (w *WinSize) Close() {
+	signal.Stop(w.sig)
 	w.quit <- true
 	<-w.wait
 }